@@ -0,0 +1,117 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ArgSpec declares a positional argument, that is, one of the
+// arguments remaining after the options. Min and Max bound how many
+// times it may appear in a row, with a negative Max meaning there is
+// no limit, so a required argument has both set to 1, an optional one
+// has only Max set to 1, and a list of one or more has Min set to 1 and
+// Max set to -1.
+//
+// Name is shown in usage synopses, and is the key under which
+// Positionals reports the arguments. Help describes the argument in
+// the help summary, where it is left out if Help is empty.
+type ArgSpec struct {
+	Name string
+	Help string
+	Min  int
+	Max  int
+}
+
+// Positionals assigns the remaining arguments to the positionals
+// declared by specs, returning them keyed by name. The specs are
+// filled in order: each first takes its Min arguments, after which any
+// extra arguments go to the earliest specs with room for them.
+// Positionals without any arguments are left out of the map.
+//
+// If there are too few or too many arguments for specs, it fails with
+// ErrArgCount.
+func Positionals(specs []ArgSpec, rest []string) (map[string][]string, error) {
+	if err := checkArgs(specs, rest); err != nil {
+		return nil, err
+	}
+
+	extra := len(rest)
+	for _, spec := range specs {
+		extra -= spec.Min
+	}
+
+	named := make(map[string][]string, len(specs))
+	for _, spec := range specs {
+		n := spec.Min
+		if room := spec.Max - spec.Min; spec.Max < 0 || room > extra {
+			n += extra
+			extra = 0
+		} else {
+			n += room
+			extra -= room
+		}
+		if n > 0 {
+			named[spec.Name] = append(named[spec.Name], rest[:n]...)
+		}
+		rest = rest[n:]
+	}
+	return named, nil
+}
+
+// checkArgs returns an ErrArgCount error if the number of remaining
+// arguments doesn't fit specs.
+func checkArgs(specs []ArgSpec, rest []string) error {
+	least, most := 0, 0
+	for _, spec := range specs {
+		least += spec.Min
+		if spec.Max < 0 || most < 0 {
+			most = -1
+		} else {
+			most += spec.Max
+		}
+	}
+
+	var detail string
+	switch got := len(rest); {
+	case least == most && got != least:
+		detail = fmt.Sprintf("expected %s, got %d", arguments(least), got)
+	case got < least:
+		detail = fmt.Sprintf("expected at least %s, got %d", arguments(least), got)
+	case most >= 0 && got > most:
+		detail = fmt.Sprintf("expected at most %s, got %d", arguments(most), got)
+	default:
+		return nil
+	}
+	return Error{Message: ErrArgCount, Err: fmt.Errorf("%s", detail)}
+}
+
+// arguments counts n arguments in English.
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// argSynopsis renders a positional argument as it appears in Usage,
+// with its optional occurrences bracketed.
+func argSynopsis(spec ArgSpec) string {
+	words := make([]string, 0, spec.Min+1)
+	for i := 0; i < spec.Min; i++ {
+		words = append(words, spec.Name)
+	}
+
+	switch {
+	case spec.Max < 0 && spec.Min > 0:
+		words[len(words)-1] += "..."
+	case spec.Max < 0:
+		words = append(words, "["+spec.Name+"...]")
+	default:
+		for i := spec.Min; i < spec.Max; i++ {
+			words = append(words, "["+spec.Name+"]")
+		}
+	}
+	return strings.Join(words, " ")
+}
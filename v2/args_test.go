@@ -0,0 +1,113 @@
+package v2
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	copySpecs := []ArgSpec{
+		{Name: "SRC", Help: "files to copy", Min: 1, Max: -1},
+		{Name: "DEST", Help: "where to copy them", Min: 1, Max: 1},
+	}
+	moveSpecs := []ArgSpec{
+		{Name: "FROM", Help: "old name", Min: 1, Max: 1},
+		{Name: "TO", Help: "new name", Min: 1, Max: 1},
+	}
+	lsSpecs := []ArgSpec{
+		{Name: "DIR", Help: "directory to list", Max: 1},
+	}
+
+	table := []struct {
+		specs []ArgSpec
+		args  []string
+		err   string
+		named map[string][]string
+	}{
+		{copySpecs, []string{"", "-a", "x"}, "expected at least 2 arguments, got 1", nil},
+		{copySpecs, []string{"", "x", "y"}, "", map[string][]string{"SRC": {"x"}, "DEST": {"y"}}},
+		{copySpecs, []string{"", "x", "y", "z"}, "", map[string][]string{"SRC": {"x", "y"}, "DEST": {"z"}}},
+		{moveSpecs, []string{"", "x"}, "expected 2 arguments, got 1", nil},
+		{moveSpecs, []string{"", "x", "y"}, "", map[string][]string{"FROM": {"x"}, "TO": {"y"}}},
+		{moveSpecs, []string{"", "-b", "x", "y", "z"}, "expected 2 arguments, got 3", nil},
+		{lsSpecs, []string{""}, "", map[string][]string{}},
+		{lsSpecs, []string{"", "x"}, "", map[string][]string{"DIR": {"x"}}},
+		{lsSpecs, []string{"", "x", "y"}, "expected at most 1 argument, got 2", nil},
+		{[]ArgSpec{}, []string{"", "x"}, "expected 0 arguments, got 1", nil},
+	}
+
+	for _, row := range table {
+		_, rest, err := ParseConfig(options, row.args, Config{Args: row.specs})
+		if row.err == "" {
+			if err != nil {
+				t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+			}
+		} else if !errors.Is(err, ErrArgCount) || err.Error() != row.err {
+			t.Errorf("ParseConfig(%q), got %v, want %q", row.args[1:], err, row.err)
+		}
+
+		named, err := Positionals(row.specs, rest)
+		if row.err == "" && err != nil {
+			t.Errorf("Positionals(%q), got error %v", rest, err)
+		} else if row.err != "" && !errors.Is(err, ErrArgCount) {
+			t.Errorf("Positionals(%q), got %v, want %q", rest, err, ErrArgCount)
+		}
+		if !reflect.DeepEqual(named, row.named) {
+			t.Errorf("Positionals(%q), got %v, want %v", rest, named, row.named)
+		}
+	}
+}
+
+func TestUsageArgs(t *testing.T) {
+	usageOptions := []Option{{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}}
+
+	table := []struct {
+		specs []ArgSpec
+		want  string
+	}{
+		{nil, "usage: prog [-v] [ARGS...]"},
+		{[]ArgSpec{}, "usage: prog [-v]"},
+		{
+			[]ArgSpec{{Name: "SRC", Min: 1, Max: -1}, {Name: "DEST", Min: 1, Max: 1}},
+			"usage: prog [-v] SRC... DEST",
+		},
+		{
+			[]ArgSpec{{Name: "PAIR", Min: 2, Max: 3}, {Name: "REST", Max: -1}},
+			"usage: prog [-v] PAIR PAIR [PAIR] [REST...]",
+		},
+	}
+
+	for _, row := range table {
+		if got := UsageArgs("prog", usageOptions, row.specs); got != row.want {
+			t.Errorf("UsageArgs(%v), got %q, want %q", row.specs, got, row.want)
+		}
+	}
+}
+
+func TestArgsHelp(t *testing.T) {
+	helpOptions := []Option{{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}}
+	specs := []ArgSpec{
+		{Name: "SRC", Help: "files to copy", Min: 1, Max: -1},
+		{Name: "DEST", Min: 1, Max: 1},
+	}
+
+	var buf bytes.Buffer
+	_, _, err := ParseConfig(helpOptions, []string{"", "--help"}, Config{Output: &buf, Args: specs})
+	if !errors.Is(err, ErrHelpRequested) {
+		t.Fatalf("ParseConfig([--help]), got %v, want %q", err, ErrHelpRequested)
+	}
+
+	// Arguments with Help follow the options, in the same columns.
+	help := buf.String()
+	want := "\n--help (-h)     Print this help message" + strings.Repeat(" ", 27) + "\n\n" +
+		"SRC             files to copy" + strings.Repeat(" ", 37) + "\n\n"
+	if !strings.HasSuffix(help, want) {
+		t.Errorf("help output doesn't end with SRC:\n%s", help)
+	}
+	if strings.Contains(help, "DEST") {
+		t.Errorf("help output shows DEST, which has no Help:\n%s", help)
+	}
+}
@@ -0,0 +1,72 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseArgs is like ParseWithOutput, but takes the arguments as a
+// single string, which is split into arguments the way a shell would,
+// as described for ExpandResponseFiles. It is meant for table-driven
+// tests, which can then write command lines as they would be typed:
+//
+//	results, rest, err := ParseArgs(options, `-v --output "my file" input`)
+//
+// The program name is supplied, so argv holds only the arguments. Help
+// and warnings are discarded.
+func ParseArgs(options []Option, argv string) ([]Result, []string, error) {
+	args, err := splitArgs(argv)
+	if err != nil {
+		return nil, nil, err
+	}
+	args = append([]string{""}, args...)
+	return ParseConfig(options, args, Config{Output: io.Discard, Warnings: io.Discard})
+}
+
+// MatchResults compares results with the expected ones, each written
+// as it would appear on a command line, by long name when there is
+// one, as in "--verbose", "-x", "--output=file", or "--no-color", and
+// "+x" for a short option negated under Config.PlusNegation. An
+// argument is always attached with "=", even when given separately or
+// with the short form, and the arguments of an option with NArgs are
+// separated by spaces, as in "--point=1 2". It returns an error
+// describing the first difference, or nil if there is none, so tests
+// needn't spell out the full Option of each Result.
+func MatchResults(results []Result, want ...string) error {
+	for i, result := range results {
+		if i == len(want) {
+			return fmt.Errorf("unexpected result %d: %s", i, matchString(result))
+		}
+		if got := matchString(result); got != want[i] {
+			return fmt.Errorf("result %d: got %s, want %s", i, got, want[i])
+		}
+	}
+	if len(results) < len(want) {
+		return fmt.Errorf("missing result %d: %s", len(results), want[len(results)])
+	}
+	return nil
+}
+
+// matchString renders a result in the form expected by MatchResults.
+func matchString(result Result) string {
+	var s string
+	if result.Long != "" && result.Negated {
+		s = "--no-" + result.Long
+	} else if result.Long != "" {
+		s = "--" + result.Long
+	} else if result.Negated {
+		s = fmt.Sprintf("+%c", result.Short)
+	} else {
+		s = fmt.Sprintf("-%c", result.Short)
+	}
+
+	if result.Optargs != nil {
+		s += "=" + strings.Join(result.Optargs, " ")
+	} else if result.HasArg {
+		s += "=" + result.Optarg
+	}
+	return s
+}
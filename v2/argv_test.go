@@ -0,0 +1,75 @@
+package v2
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	color := Option{Long: "color", Kind: KindOptional, Help: "colorize output", Negatable: true}
+	point := Option{Short: 'p', Kind: KindRequired, Help: "plot X and Y", NArgs: 2}
+	argvOptions := []Option{verbose, output, color, point}
+
+	table := []struct {
+		argv    string
+		results []string
+		rest    []string
+	}{
+		{"", nil, nil},
+		{"-v input", []string{"--verbose"}, []string{"input"}},
+		{`-o "my file" input`, []string{"--output=my file"}, []string{"input"}},
+		{`--output='it''s' 'a b'`, []string{"--output=its"}, []string{"a b"}},
+		{`-o my\ file a\"b`, []string{"--output=my file"}, []string{`a"b`}},
+		{`-vo "" --color --no-color`, []string{"--verbose", "--output=", "--color", "--no-color"}, nil},
+		{"--color=auto -p 1 2", []string{"--color=auto", "-p=1 2"}, nil},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseArgs(argvOptions, row.argv)
+		if err != nil {
+			t.Errorf("ParseArgs(%q), got error %v", row.argv, err)
+			continue
+		}
+		if err := MatchResults(results, row.results...); err != nil {
+			t.Errorf("ParseArgs(%q), %v", row.argv, err)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseArgs(%q), got rest %q, want %q", row.argv, rest, row.rest)
+		}
+	}
+
+	if _, _, err := ParseArgs(argvOptions, `-o "unterminated`); err == nil {
+		t.Errorf("ParseArgs() with an unterminated quote, got no error")
+	}
+	if _, _, err := ParseArgs(argvOptions, "--help"); !errors.Is(err, ErrHelpRequested) {
+		t.Errorf("ParseArgs(--help), got %v, want %q", err, ErrHelpRequested)
+	}
+}
+
+func TestMatchResults(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	results := []Result{{Option: verbose}, {Option: verbose}}
+
+	table := []struct {
+		want []string
+		err  string
+	}{
+		{[]string{"--verbose", "--verbose"}, ""},
+		{[]string{"--verbose", "-v"}, "result 1: got --verbose, want -v"},
+		{[]string{"--verbose"}, "unexpected result 1: --verbose"},
+		{[]string{"--verbose", "--verbose", "--quiet"}, "missing result 2: --quiet"},
+	}
+
+	for _, row := range table {
+		err := MatchResults(results, row.want...)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != row.err {
+			t.Errorf("MatchResults(%q), got %q, want %q", row.want, got, row.err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+// OptionBuilder builds an Option through chained method calls, as an
+// alternative to a struct literal:
+//
+//	verbose, err := NewOption("verbose", 'v').WithHelp("be verbose").Build()
+//	output, err := NewOption("output", 'o').WithKind(KindRequired).WithHelp("write to FILE").Build()
+//
+// Build checks the option on its own the way Parse would, so mistakes
+// such as a missing Help surface where the option is defined.
+type OptionBuilder struct {
+	option Option
+}
+
+// NewOption starts building an option with the given long and short
+// forms, either of which may be the zero value, as in an Option. The
+// option takes no argument until WithKind says otherwise.
+func NewOption(long string, short rune) *OptionBuilder {
+	return &OptionBuilder{option: Option{Long: long, Short: short, Kind: KindNone}}
+}
+
+// WithKind sets the option's Kind, which decides whether it takes an
+// argument.
+func (b *OptionBuilder) WithKind(kind Kind) *OptionBuilder {
+	b.option.Kind = kind
+	return b
+}
+
+// WithRequired sets the option's Required, so that it must appear on
+// the command line. This is unrelated to KindRequired.
+func (b *OptionBuilder) WithRequired() *OptionBuilder {
+	b.option.Required = true
+	return b
+}
+
+// WithNegatable sets the option's Negatable.
+func (b *OptionBuilder) WithNegatable() *OptionBuilder {
+	b.option.Negatable = true
+	return b
+}
+
+// WithHidden sets the option's Hidden.
+func (b *OptionBuilder) WithHidden() *OptionBuilder {
+	b.option.Hidden = true
+	return b
+}
+
+// WithAliases sets the option's Aliases.
+func (b *OptionBuilder) WithAliases(aliases ...string) *OptionBuilder {
+	b.option.Aliases = aliases
+	return b
+}
+
+// WithHelp sets the option's Help.
+func (b *OptionBuilder) WithHelp(help string) *OptionBuilder {
+	b.option.Help = help
+	return b
+}
+
+// WithHelpFunc sets the option's HelpFunc.
+func (b *OptionBuilder) WithHelpFunc(help func() string) *OptionBuilder {
+	b.option.HelpFunc = help
+	return b
+}
+
+// WithDefault sets the option's Default.
+func (b *OptionBuilder) WithDefault(value string) *OptionBuilder {
+	b.option.Default = value
+	return b
+}
+
+// WithMetavar sets the option's Metavar.
+func (b *OptionBuilder) WithMetavar(metavar string) *OptionBuilder {
+	b.option.Metavar = metavar
+	return b
+}
+
+// WithEnv sets the option's Env.
+func (b *OptionBuilder) WithEnv(name string) *OptionBuilder {
+	b.option.Env = name
+	return b
+}
+
+// WithChoices sets the option's Choices.
+func (b *OptionBuilder) WithChoices(choices ...string) *OptionBuilder {
+	b.option.Choices = choices
+	return b
+}
+
+// WithPattern sets the option's Pattern.
+func (b *OptionBuilder) WithPattern(pattern string) *OptionBuilder {
+	b.option.Pattern = pattern
+	return b
+}
+
+// Build returns the option, or an error if it has neither a long nor
+// a short form, which fails with ErrUnnamed, if it has neither Help
+// nor HelpFunc, which fails with ErrHelpMissing, or if it has settings
+// Parse would reject: ErrArgSetting for a KindNone option with a
+// setting only meant for arguments, such as a Default, and
+// ErrBadPattern for a Pattern that doesn't compile. Whether the option
+// clashes with others can only be told by Parse.
+func (b *OptionBuilder) Build() (Option, error) {
+	if b.option.Long == "" && b.option.Short == 0 {
+		return b.option, Error{Option: b.option, Message: ErrUnnamed}
+	}
+	if b.option.Help == "" && b.option.HelpFunc == nil {
+		return b.option, Error{Option: b.option, Message: ErrHelpMissing}
+	}
+	if _, err := checkOption(b.option); err != nil {
+		return b.option, err
+	}
+	return b.option, nil
+}
+
+// MustBuild is like Build, but panics on error. It simplifies defining
+// options in package-level variables.
+func (b *OptionBuilder) MustBuild() Option {
+	option, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return option
+}
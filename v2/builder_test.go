@@ -0,0 +1,88 @@
+package v2
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOptionBuilder(t *testing.T) {
+	table := []struct {
+		builder *OptionBuilder
+		want    Option
+	}{
+		{
+			NewOption("verbose", 'v').WithHelp("be verbose"),
+			Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+		},
+		{
+			NewOption("output", 'o').WithKind(KindRequired).WithHelp("write to FILE").WithMetavar("FILE"),
+			Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE", Metavar: "FILE"},
+		},
+		{
+			NewOption("color", 0).WithKind(KindOptional).WithDefault("auto").WithChoices("auto", "never").WithHelp("colorize"),
+			Option{Long: "color", Kind: KindOptional, Help: "colorize", Default: "auto", Choices: []string{"auto", "never"}},
+		},
+		{
+			NewOption("", 'x').WithKind(KindRequired).WithEnv("X").WithHelp("use X"),
+			Option{Short: 'x', Kind: KindRequired, Help: "use X", Env: "X"},
+		},
+		{
+			NewOption("color", 0).WithNegatable().WithAliases("colour").WithHelp("colorize"),
+			Option{Long: "color", Kind: KindNone, Help: "colorize", Negatable: true, Aliases: []string{"colour"}},
+		},
+		{
+			NewOption("config", 'c').WithKind(KindRequired).WithRequired().WithHidden().WithHelp("read FILE"),
+			Option{Long: "config", Short: 'c', Kind: KindRequired, Help: "read FILE", Required: true, Hidden: true},
+		},
+	}
+
+	for _, row := range table {
+		got, err := row.builder.Build()
+		if err != nil {
+			t.Errorf("Build(), got error %v", err)
+		}
+		if !reflect.DeepEqual(got, row.want) {
+			t.Errorf("Build(), got %+v, want %+v", got, row.want)
+		}
+		if got := row.builder.MustBuild(); !reflect.DeepEqual(got, row.want) {
+			t.Errorf("MustBuild(), got %+v, want %+v", got, row.want)
+		}
+	}
+}
+
+func TestOptionBuilderErrors(t *testing.T) {
+	_, err := NewOption("verbose", 'v').Build()
+	want := Error{Option: Option{Long: "verbose", Short: 'v'}, Message: ErrHelpMissing}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Build() without help, got %v, want %v", err, want)
+	}
+
+	_, err = NewOption("", 0).WithHelp("nameless").Build()
+	want = Error{Option: Option{Help: "nameless"}, Message: ErrUnnamed}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Build() without names, got %v, want %v", err, want)
+	}
+	if err.Error() != "option has no name" {
+		t.Errorf("Build() without names, got %q, want %q", err.Error(), "option has no name")
+	}
+
+	// Settings Parse would reject fail here already.
+	if _, err := NewOption("x", 'x').WithDefault("a").WithHelp("h").Build(); !errors.Is(err, ErrArgSetting) {
+		t.Errorf("Build() with a Default for a KindNone option, got %v, want %q", err, ErrArgSetting)
+	}
+	if _, err := NewOption("tag", 0).WithKind(KindRequired).WithPattern("[").WithHelp("h").Build(); !errors.Is(err, ErrBadPattern) {
+		t.Errorf("Build() with a bad Pattern, got %v, want %q", err, ErrBadPattern)
+	}
+
+	if _, err := NewOption("verbose", 'v').WithHelpFunc(func() string { return "be verbose" }).Build(); err != nil {
+		t.Errorf("Build() with a HelpFunc, got error %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustBuild() without help, got no panic")
+		}
+	}()
+	NewOption("verbose", 'v').MustBuild()
+}
@@ -0,0 +1,71 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+// Command is a subcommand in the style of "git commit" or "git push",
+// with its own set of options. Different commands may reuse the same
+// option names for unrelated purposes.
+type Command struct {
+	Name    string
+	Help    string
+	Options []Option
+
+	// Handler, if not nil, is called by ParseCommands once the
+	// command's arguments have been parsed successfully.
+	Handler func(results []Result, rest []string) error
+}
+
+// ParseCommands parses a command line made up of global options, a
+// subcommand name, and that subcommand's own options and arguments.
+//
+// The global options are parsed as with ParseWithOutput writing to
+// standard output, stopping at the first non-option argument, which
+// selects the subcommand. The arguments following it are then parsed
+// against the subcommand's options. The matched command is returned,
+// along with the global results followed by the command's results,
+// and the command's remaining arguments. The command's Handler is
+// called with its own results before returning.
+//
+// If no subcommand is given, or it doesn't match the Name of any of
+// the commands, an Error with the ErrCommand message is returned.
+func ParseCommands(options []Option, commands []Command, args []string) (*Command, []Result, []string, error) {
+	results, rest, err := ParseWithOutput(options, args, nil)
+	if err != nil {
+		return nil, results, rest, err
+	}
+
+	if len(rest) == 0 {
+		return nil, results, rest, Error{Message: ErrCommand}
+	}
+
+	var command *Command
+	for i := range commands {
+		if commands[i].Name == rest[0] {
+			command = &commands[i]
+			break
+		}
+	}
+	if command == nil {
+		return nil, results, rest, Error{Message: ErrCommand, Command: rest[0]}
+	}
+
+	// The command name takes the place of args[0], which
+	// ParseWithOutput skips.
+	// Positions in the command's arguments are shifted to match
+	// args.
+	offset := len(args) - len(rest)
+	commandResults, rest, err := ParseWithOutput(command.Options, rest, nil)
+	results = append(results, commandResults...)
+	if err != nil {
+		if e, ok := err.(Error); ok && e.Token != "" {
+			e.Index += offset
+			err = e
+		}
+		return command, results, rest, err
+	}
+
+	if command.Handler != nil {
+		err = command.Handler(commandResults, rest)
+	}
+	return command, results, rest, err
+}
@@ -0,0 +1,104 @@
+package v2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	fixup := Option{Long: "fixup", Short: 'f', Kind: KindRequired, Help: "fix up COMMIT"}
+	message := Option{Long: "message", Short: 'm', Kind: KindRequired, Help: "use MSG as the message"}
+	force := Option{Long: "force", Short: 'f', Kind: KindNone, Help: "force the update"}
+
+	var handled []string
+	handler := func(name string) func([]Result, []string) error {
+		return func(results []Result, rest []string) error {
+			handled = append(handled, name)
+			return nil
+		}
+	}
+
+	commands := []Command{
+		{Name: "commit", Help: "record changes", Options: []Option{fixup, message}, Handler: handler("commit")},
+		{Name: "push", Help: "update remote refs", Options: []Option{force}, Handler: handler("push")},
+	}
+	globals := []Option{verbose}
+
+	table := []struct {
+		args    []string
+		command string
+		results []Result
+		rest    []string
+		err     error
+	}{
+		{
+			[]string{"git", "-v", "commit", "-f", "abc123", "-m", "fix", "file.go"},
+			"commit",
+			[]Result{
+				{Option: verbose},
+				{Option: fixup, Optarg: "abc123", HasArg: true},
+				{Option: message, Optarg: "fix", HasArg: true},
+			},
+			[]string{"file.go"},
+			nil,
+		},
+		{
+			[]string{"git", "push", "-f", "origin"},
+			"push",
+			[]Result{{Option: force}},
+			[]string{"origin"},
+			nil,
+		},
+		{
+			[]string{"git", "-v"},
+			"",
+			[]Result{{Option: verbose}},
+			[]string{},
+			Error{Message: ErrCommand},
+		},
+		{
+			[]string{"git", "pull"},
+			"",
+			nil,
+			[]string{"pull"},
+			Error{Message: ErrCommand, Command: "pull"},
+		},
+		{
+			[]string{"git", "push", "-m", "oops"},
+			"push",
+			nil,
+			[]string{"-m", "oops"},
+			Error{Option: Option{Short: 'm'}, Message: ErrInvalid, Token: "-m", Index: 2},
+		},
+	}
+
+	for _, row := range table {
+		handled = nil
+		command, results, rest, err := ParseCommands(globals, commands, row.args)
+
+		name := ""
+		if command != nil {
+			name = command.Name
+		}
+		if name != row.command {
+			t.Errorf("ParseCommands(%q), got command %q, want %q", row.args[1:], name, row.command)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseCommands(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseCommands(%q), got %v, want %v", row.args[1:], rest, row.rest)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseCommands(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+
+		if row.err == nil && !equal(handled, []string{row.command}) {
+			t.Errorf("ParseCommands(%q), handlers called %v, want [%s]", row.args[1:], handled, row.command)
+		}
+		if row.err != nil && handled != nil {
+			t.Errorf("ParseCommands(%q), handlers called %v on error", row.args[1:], handled)
+		}
+	}
+}
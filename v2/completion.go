@@ -0,0 +1,203 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// completeFlag is the hidden option that asks for completions, as
+// described for Option.Complete.
+const completeFlag = "--_complete"
+
+// GenBashCompletion returns a bash completion script for progName,
+// which completes the names of the given options, except Hidden ones,
+// as well as --help. After an option that requires an argument, file
+// names are completed instead, unless the option has Complete, in
+// which case the program is run with --_complete to list the
+// completions. The script can be saved to a file and sourced.
+func GenBashCompletion(progName string, options []Option) string {
+	options = append(options[:len(options):len(options)], helpOption)
+
+	var words, withArg, withComplete []string
+	for _, option := range options {
+		if option.Hidden {
+			continue
+		}
+		names := flagNames(option)
+		words = append(words, names...)
+		if option.Kind == KindRequired && option.Complete != nil {
+			withComplete = append(withComplete, names...)
+		} else if option.Kind == KindRequired {
+			withArg = append(withArg, names...)
+		}
+	}
+
+	function := "_" + identifier(progName)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", progName)
+	fmt.Fprintf(&b, "%s() {\n", function)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    local prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	if withArg != nil || withComplete != nil {
+		b.WriteString("    case \"$prev\" in\n")
+		if withComplete != nil {
+			fmt.Fprintf(&b, "        %s)\n", strings.Join(withComplete, "|"))
+			fmt.Fprintf(&b, "            COMPREPLY=($(\"${COMP_WORDS[0]}\" %s \"$prev\" \"$cur\"))\n", completeFlag)
+			b.WriteString("            return\n")
+			b.WriteString("            ;;\n")
+		}
+		if withArg != nil {
+			fmt.Fprintf(&b, "        %s)\n", strings.Join(withArg, "|"))
+			b.WriteString("            COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+			b.WriteString("            return\n")
+			b.WriteString("            ;;\n")
+		}
+		b.WriteString("    esac\n")
+	}
+	fmt.Fprintf(&b, "    COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(words, " "))
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", function, progName)
+	return b.String()
+}
+
+// GenZshCompletion returns a zsh completion script for progName, which
+// completes the names of the given options, except Hidden ones, as
+// well as --help, along with their descriptions. Arguments are
+// completed as file names. The script is meant to be saved as
+// "_progName" in a directory on $fpath.
+func GenZshCompletion(progName string, options []Option) string {
+	options = append(options[:len(options):len(options)], helpOption)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", progName)
+	b.WriteString("_arguments \\\n")
+	for _, option := range options {
+		if !option.Hidden {
+			fmt.Fprintf(&b, "    %s \\\n", zshSpec(option))
+		}
+	}
+	b.WriteString("    '*:file:_files'\n")
+	return b.String()
+}
+
+// complete returns the completions of the last of the given words,
+// which were typed after the program name. An argument attached to a
+// long option, as in "--branch=ma", or following an option that
+// requires one, as in "--branch ma", is completed by the option's
+// Complete. Other words starting with "-" are completed as the names
+// of options that aren't Hidden, and anything else has no completions.
+func complete(options []Option, words []string) []string {
+	var word, prev string
+	if len(words) > 0 {
+		word = words[len(words)-1]
+	}
+	if len(words) > 1 {
+		prev = words[len(words)-2]
+	}
+
+	if name, prefix, ok := strings.Cut(word, "="); ok && strings.HasPrefix(name, "--") {
+		option, _ := findLong(options, name[2:])
+		if option == nil || option.Complete == nil || option.Kind == KindNone {
+			return nil
+		}
+		var candidates []string
+		for _, candidate := range option.Complete(prefix) {
+			candidates = append(candidates, name+"="+candidate)
+		}
+		return candidates
+	}
+
+	for _, option := range options {
+		if option.Kind == KindRequired && option.Complete != nil && contains(flagNames(option), prev) {
+			return option.Complete(word)
+		}
+	}
+
+	if !strings.HasPrefix(word, "-") {
+		return nil
+	}
+	var candidates []string
+	for _, option := range options {
+		if option.Hidden {
+			continue
+		}
+		for _, name := range flagNames(option) {
+			if strings.HasPrefix(name, word) {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	return candidates
+}
+
+// zshSpec renders an option as an _arguments specification. Required
+// arguments may be attached or given separately, while optional ones
+// must be attached, as the parser expects.
+func zshSpec(option Option) string {
+	var short, long, arg string
+	switch option.Kind {
+	case KindNone:
+		short, long = "", ""
+	case KindRequired:
+		short, long = "+", "="
+		arg = ":" + zshEscape(metavar(option)) + ":_files"
+	case KindOptional:
+		short, long = "-", "=-"
+		arg = "::" + zshEscape(metavar(option)) + ":_files"
+	}
+
+	var flags []string
+	if option.Short != 0 {
+		flags = append(flags, fmt.Sprintf("-%c%s", option.Short, short))
+	}
+	for _, name := range option.longNames() {
+		flags = append(flags, "--"+name+long)
+	}
+	description := "[" + zshEscape(firstLine(option.helpText())) + "]" + arg
+
+	if len(flags) == 1 {
+		return "'" + flags[0] + description + "'"
+	}
+	return fmt.Sprintf("'(%s)'{%s}'%s'",
+		strings.Join(flagNames(option), " "), strings.Join(flags, ","), description)
+}
+
+// flagNames returns every name an option can be given by on the
+// command line, such as "-o" and "--output".
+func flagNames(option Option) []string {
+	var names []string
+	if option.Short != 0 {
+		names = append(names, fmt.Sprintf("-%c", option.Short))
+	}
+	for _, name := range option.longNames() {
+		names = append(names, "--"+name)
+	}
+	return names
+}
+
+// zshEscape escapes the characters that are special within a quoted
+// _arguments specification.
+func zshEscape(s string) string {
+	s = strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+	return strings.ReplaceAll(s, "'", `'\''`)
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	scanner.Scan()
+	return scanner.Text()
+}
+
+// identifier turns s into a valid shell function name.
+func identifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
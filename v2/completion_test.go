@@ -0,0 +1,134 @@
+package v2
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var completionOptions = []Option{
+	{Long: "amend", Short: 'a', Kind: KindNone, Help: "amend a foo"},
+	{Long: "color", Short: 'c', Kind: KindOptional, Help: "colorize output [auto]"},
+	{Long: "delay", Short: 'd', Kind: KindRequired, Help: "delay ARG milliseconds\nmore detail"},
+	{Long: "output", Kind: KindRequired, Help: "write to FILE", Metavar: "FILE"},
+	{Short: 's', Kind: KindNone, Help: "quick switch configuration"},
+	{Long: "secret", Kind: KindRequired, Help: "s", Hidden: true},
+}
+
+func TestGenBashCompletion(t *testing.T) {
+	script := GenBashCompletion("my-prog", completionOptions)
+
+	for _, want := range []string{
+		"_my_prog() {",
+		`compgen -W "-a --amend -c --color -d --delay --output -s -h --help"`,
+		"        -d|--delay|--output)\n",
+		"complete -o default -F _my_prog my-prog\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("GenBashCompletion() is missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "--secret") {
+		t.Errorf("GenBashCompletion() includes the hidden --secret:\n%s", script)
+	}
+}
+
+func TestGenBashCompletionCallback(t *testing.T) {
+	branch := Option{Long: "branch", Short: 'b', Kind: KindRequired, Help: "check out BRANCH", Complete: branches}
+	script := GenBashCompletion("prog", append(completionOptions[:5:5], branch))
+
+	for _, want := range []string{
+		"        -b|--branch)\n" +
+			"            COMPREPLY=($(\"${COMP_WORDS[0]}\" --_complete \"$prev\" \"$cur\"))\n",
+		"        -d|--delay|--output)\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("GenBashCompletion() is missing %q:\n%s", want, script)
+		}
+	}
+}
+
+// branches completes the names of some branches.
+func branches(prefix string) []string {
+	var found []string
+	for _, branch := range []string{"main", "master", "next"} {
+		if strings.HasPrefix(branch, prefix) {
+			found = append(found, branch)
+		}
+	}
+	return found
+}
+
+func TestComplete(t *testing.T) {
+	branch := Option{Long: "branch", Short: 'b', Kind: KindRequired, Help: "check out BRANCH", Complete: branches}
+	secret := Option{Long: "secret", Kind: KindNone, Help: "do it quietly", Hidden: true}
+	completeOptions := append(completionOptions[:5:5], branch, secret)
+
+	table := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"--branch", "ma"}, "main\nmaster\n"},
+		{[]string{"-v", "-b", ""}, "main\nmaster\nnext\n"},
+		{[]string{"--branch=ma"}, "--branch=main\n--branch=master\n"},
+		{[]string{"--br=n"}, "--br=next\n"},
+		{[]string{"--delay="}, ""},
+		{[]string{"--delay", "ma"}, ""},
+		{[]string{"--b"}, "--branch\n"},
+		{[]string{"file", "--s"}, ""},
+		{[]string{"-"}, "-a\n--amend\n-c\n--color\n-d\n--delay\n--output\n-s\n-b\n--branch\n-h\n--help\n"},
+		{[]string{"ma"}, ""},
+		{[]string{}, ""},
+	}
+
+	for _, row := range table {
+		var buf bytes.Buffer
+		args := append([]string{"", "--_complete"}, row.words...)
+		results, rest, err := ParseWithOutput(completeOptions, args, &buf)
+		if got := buf.String(); got != row.want {
+			t.Errorf("ParseWithOutput(%q), printed %q, want %q", args[1:], got, row.want)
+		}
+		if !errors.Is(err, ErrCompletion) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %q", args[1:], err, ErrCompletion)
+		}
+		if len(results) != 0 || len(rest) != 0 {
+			t.Errorf("ParseWithOutput(%q), got results %v and rest %q, want none", args[1:], results, rest)
+		}
+	}
+
+	// Only the first argument asks for completions.
+	results, _, err := ParseWithOutput(completeOptions, []string{"", "-b", "--_complete"}, nil)
+	if err != nil {
+		t.Errorf("ParseWithOutput([-b --_complete]), got error %v", err)
+	} else if err := MatchResults(results, "--branch=--_complete"); err != nil {
+		t.Errorf("ParseWithOutput([-b --_complete]), %v", err)
+	}
+
+	// Without any Complete, --_complete is an option like any other.
+	_, _, err = ParseWithOutput(completionOptions, []string{"", "--_complete", "-"}, nil)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseWithOutput([--_complete -]) without Complete, got %v, want %q", err, ErrInvalid)
+	}
+}
+
+func TestGenZshCompletion(t *testing.T) {
+	script := GenZshCompletion("prog", completionOptions)
+
+	for _, want := range []string{
+		"#compdef prog\n",
+		`'(-a --amend)'{-a,--amend}'[amend a foo]'`,
+		`'(-c --color)'{-c-,--color=-}'[colorize output \[auto\]]::COLOR:_files'`,
+		`'(-d --delay)'{-d+,--delay=}'[delay ARG milliseconds]:DELAY:_files'`,
+		`'--output=[write to FILE]:FILE:_files'`,
+		`'-s[quick switch configuration]'`,
+		`'(-h --help)'{-h,--help}'[Print this help message]'`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("GenZshCompletion() is missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "--secret") {
+		t.Errorf("GenZshCompletion() includes the hidden --secret:\n%s", script)
+	}
+}
@@ -0,0 +1,103 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// ParseWithConfig is like ParseWithOutput writing to standard output,
+// but options not given on the command line are taken from the JSON
+// file at configPath. See Config.ConfigFile for its format.
+func ParseWithConfig(options []Option, args []string, configPath string) ([]Result, []string, error) {
+	return ParseConfig(options, args, Config{ConfigFile: configPath})
+}
+
+// appendFile appends a result for each option that is set in the JSON
+// config file at path, but was given neither on the command line nor
+// through its Env variable. Keys not naming any option are reported to
+// warnings.
+func appendFile(path string, options []Option, results []Result, warnings io.Writer) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return results, err
+	}
+
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return results, fmt.Errorf("%s: %w", path, err)
+	}
+
+	known := make(map[string]bool, len(values))
+	for _, option := range options {
+		for _, name := range option.longNames() {
+			value, ok := values[name]
+			if !ok {
+				continue
+			}
+			known[name] = true
+			if Count(results, option.Long, option.Short) > 0 {
+				continue
+			}
+
+			result, ok, err := fileResult(option, value)
+			if err != nil {
+				return results, fmt.Errorf("%s: %q: %w", path, name, err)
+			}
+			if ok {
+				results = append(results, result)
+			}
+		}
+	}
+
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		fmt.Fprintf(warnings, "warning: %s: unknown option %q\n", path, key)
+	}
+	return results, nil
+}
+
+// fileResult converts a value from a config file into a result for
+// option. A KindNone option takes a boolean, and is only given when
+// it's true, unless it's Negatable, in which case false gives the
+// negated form. Other options take a string, number, or boolean as
+// their argument. A null value means the option isn't given.
+func fileResult(option Option, value any) (Result, bool, error) {
+	if value == nil {
+		return Result{}, false, nil
+	}
+
+	if option.Kind == KindNone {
+		b, ok := value.(bool)
+		if !ok {
+			return Result{}, false, fmt.Errorf("expected a boolean, got %v", value)
+		}
+		if !b && !option.Negatable {
+			return Result{}, false, nil
+		}
+		return Result{Option: option, Negated: !b}, true, nil
+	}
+
+	switch value := value.(type) {
+	case string:
+		return Result{Option: option, Optarg: value, HasArg: true}, true, nil
+	case json.Number:
+		return Result{Option: option, Optarg: value.String(), HasArg: true}, true, nil
+	case bool:
+		return Result{Option: option, Optarg: fmt.Sprint(value), HasArg: true}, true, nil
+	}
+	return Result{}, false, fmt.Errorf("expected a string, number, or boolean, got %v", value)
+}
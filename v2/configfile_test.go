@@ -0,0 +1,104 @@
+package v2
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFile(t *testing.T) {
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	jobs := Option{Long: "jobs", Short: 'j', Kind: KindRequired, Help: "run N jobs"}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	color := Option{Long: "color", Kind: KindNone, Help: "colorize output", Negatable: true}
+	fileOptions := []Option{output, jobs, verbose, color}
+
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.json", `{
+		"output": "out.txt",
+		"jobs": 4,
+		"verbose": true,
+		"color": false,
+		"colour": true,
+		"extra": 1
+	}`)
+
+	table := []struct {
+		args    []string
+		results []Result
+	}{
+		// config only
+		{
+			[]string{""},
+			[]Result{
+				{Option: output, Optarg: "out.txt", HasArg: true},
+				{Option: jobs, Optarg: "4", HasArg: true},
+				{Option: verbose},
+				{Option: color, Negated: true},
+			},
+		},
+		// the command line overrides the config
+		{
+			[]string{"", "-o", "cli.txt", "--color"},
+			[]Result{
+				{Option: output, Optarg: "cli.txt", HasArg: true},
+				{Option: color},
+				{Option: jobs, Optarg: "4", HasArg: true},
+				{Option: verbose},
+			},
+		},
+	}
+
+	for _, row := range table {
+		var warnings bytes.Buffer
+		conf := Config{ConfigFile: path, Warnings: &warnings}
+		results, _, err := ParseConfig(fileOptions, row.args, conf)
+		if err != nil {
+			t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+
+		// unknown keys are warned about, in order
+		want := "warning: " + path + ": unknown option \"colour\"\n" +
+			"warning: " + path + ": unknown option \"extra\"\n"
+		if got := warnings.String(); got != want {
+			t.Errorf("ParseConfig(%q) warned %q, want %q", row.args[1:], got, want)
+		}
+	}
+
+	// The config is validated like the command line.
+	ranged := jobs
+	ranged.Range = &Range{Min: 1, Max: 2}
+	_, _, err := ParseConfig([]Option{ranged}, []string{""}, Config{ConfigFile: path, Warnings: &bytes.Buffer{}})
+	if !errors.Is(err, ErrRange) {
+		t.Errorf("ParseConfig() with a ranged option, got %v, want %q", err, ErrRange)
+	}
+
+	// The config never conflicts with the command line, which
+	// leaves it out instead.
+	quiet := Option{Long: "quiet", Short: 'q', Kind: KindNone, Help: "be quiet"}
+	quietPath := writeFile(t, dir, "quiet.json", `{"quiet": true}`)
+	conf := Config{ConfigFile: quietPath, Exclusive: [][]string{{"verbose", "quiet"}}}
+	results, _, err := ParseConfig([]Option{verbose, quiet}, []string{"", "-v"}, conf)
+	if want := []Result{{Option: verbose}}; err != nil || !reflect.DeepEqual(results, want) {
+		t.Errorf("ParseConfig([-v]) with an exclusive config, got %v and %v, want %v", results, err, want)
+	}
+
+	// Bad files are errors.
+	bad := writeFile(t, dir, "bad.json", `{"verbose": "yes"}`)
+	if _, _, err := ParseConfig(fileOptions, []string{""}, Config{ConfigFile: bad}); err == nil {
+		t.Error("ParseConfig() with a string for a KindNone option, expected an error")
+	}
+	malformed := writeFile(t, dir, "malformed.json", `{"output":`)
+	if _, _, err := ParseConfig(fileOptions, []string{""}, Config{ConfigFile: malformed}); err == nil {
+		t.Error("ParseConfig() with malformed JSON, expected an error")
+	}
+	_, _, err = ParseWithConfig(fileOptions, []string{""}, dir+"/missing.json")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ParseWithConfig() with a missing file, got %v, want %q", err, os.ErrNotExist)
+	}
+}
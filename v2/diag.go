@@ -0,0 +1,56 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Diagnostics gathers everything worth reporting about a command line,
+// so that a program can render it as a single, structured report.
+type Diagnostics struct {
+	// Errors holds every error found, in order, as with ParseAll.
+	// Most are of type Error, where Token gives the argument at
+	// fault and Index its position, but some, such as those
+	// reading a ConfigFile, are not.
+	Errors []error
+
+	// Warnings holds the warnings that would otherwise be written
+	// to Config.Warnings, such as for deprecated options or
+	// unknown keys in a ConfigFile, without their "warning: "
+	// prefix.
+	Warnings []string
+}
+
+// Err returns the first of the errors, or nil if there are none.
+func (d Diagnostics) Err() error {
+	if len(d.Errors) == 0 {
+		return nil
+	}
+	return d.Errors[0]
+}
+
+// ParseDiag is like ParseAll, but returns the errors along with any
+// warnings as Diagnostics, instead of writing the warnings out.
+func ParseDiag(options []Option, args []string) ([]Result, []string, Diagnostics) {
+	return ParseDiagConfig(options, args, Config{})
+}
+
+// ParseDiagConfig is like ParseDiag, but parsing is controlled by
+// config, as with ParseConfig, so that a ConfigFile can be read. The
+// Warnings of config are ignored, since the warnings are collected.
+func ParseDiagConfig(options []Option, args []string, config Config) ([]Result, []string, Diagnostics) {
+	var warnings bytes.Buffer
+	config.Warnings = &warnings
+	results, rest, errs := parseArgs(options, args, config, true)
+
+	var diag Diagnostics
+	diag.Errors = errs
+	for _, line := range strings.Split(strings.TrimSuffix(warnings.String(), "\n"), "\n") {
+		if line != "" {
+			diag.Warnings = append(diag.Warnings, strings.TrimPrefix(line, "warning: "))
+		}
+	}
+	return results, rest, diag
+}
@@ -0,0 +1,75 @@
+package v2
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiag(t *testing.T) {
+	old := Option{Long: "old", Short: 'O', Kind: KindNone, Help: "the old way", Deprecated: "use --new instead"}
+	level := Option{Long: "level", Kind: KindRequired, Help: "use LEVEL", Range: &Range{Min: 1, Max: 9}}
+	diagOptions := []Option{old, level}
+
+	args := []string{"", "--old", "-x", "--level", "12", "-O", "--bogus", "file"}
+	results, rest, diag := ParseDiag(diagOptions, args)
+
+	if want := []Result{{Option: old}, {Option: old}}; !reflect.DeepEqual(results, want) {
+		t.Errorf("ParseDiag(%q), got %v, want %v", args[1:], results, want)
+	}
+	if !equal(rest, []string{"file"}) {
+		t.Errorf("ParseDiag(%q), got rest %q, want [file]", args[1:], rest)
+	}
+
+	wantErrs := []error{
+		Error{Option: Option{Short: 'x'}, Message: ErrInvalid, Token: "-x", Index: 2},
+		Error{Option: level, Message: ErrRange, Optarg: "12", Token: "--level", Index: 3},
+		Error{Option: Option{Long: "bogus"}, Message: ErrInvalid, Token: "--bogus", Index: 6},
+	}
+	if !reflect.DeepEqual(diag.Errors, wantErrs) {
+		t.Errorf("ParseDiag(%q), got errors %v, want %v", args[1:], diag.Errors, wantErrs)
+	}
+	if !errors.Is(diag.Err(), ErrInvalid) {
+		t.Errorf("ParseDiag(%q), got Err() = %v, want %q", args[1:], diag.Err(), ErrInvalid)
+	}
+
+	wantWarnings := []string{"--old (-O) is deprecated; use --new instead"}
+	if !equal(diag.Warnings, wantWarnings) {
+		t.Errorf("ParseDiag(%q), got warnings %q, want %q", args[1:], diag.Warnings, wantWarnings)
+	}
+
+	// A repeated token is told apart by its position.
+	_, _, diag = ParseDiag(diagOptions, []string{"", "-x", "--level=3", "-x"})
+	wantErrs = []error{
+		Error{Option: Option{Short: 'x'}, Message: ErrInvalid, Token: "-x", Index: 1},
+		Error{Option: Option{Short: 'x'}, Message: ErrInvalid, Token: "-x", Index: 3},
+	}
+	if !reflect.DeepEqual(diag.Errors, wantErrs) {
+		t.Errorf("ParseDiag([-x --level=3 -x]), got errors %v, want %v", diag.Errors, wantErrs)
+	}
+
+	_, _, diag = ParseDiag(diagOptions, []string{"", "--level=3"})
+	if diag.Errors != nil || diag.Warnings != nil || diag.Err() != nil {
+		t.Errorf("ParseDiag([--level=3]), got %+v, want no diagnostics", diag)
+	}
+}
+
+func TestParseDiagConfig(t *testing.T) {
+	level := Option{Long: "level", Kind: KindRequired, Help: "use LEVEL", Range: &Range{Min: 1, Max: 9}}
+	path := writeFile(t, t.TempDir(), "config.json", `{"level": 12, "colour": true}`)
+
+	_, _, diag := ParseDiagConfig([]Option{level}, []string{""}, Config{ConfigFile: path})
+	wantErrs := []error{Error{Option: level, Message: ErrRange, Optarg: "12"}}
+	if !reflect.DeepEqual(diag.Errors, wantErrs) {
+		t.Errorf("ParseDiagConfig(), got errors %v, want %v", diag.Errors, wantErrs)
+	}
+	wantWarnings := []string{path + `: unknown option "colour"`}
+	if !equal(diag.Warnings, wantWarnings) {
+		t.Errorf("ParseDiagConfig(), got warnings %q, want %q", diag.Warnings, wantWarnings)
+	}
+
+	_, _, diag = ParseDiagConfig([]Option{level}, []string{""}, Config{ConfigFile: path + ".missing"})
+	if len(diag.Errors) != 1 || diag.Warnings != nil {
+		t.Errorf("ParseDiagConfig() with a missing file, got %+v, want one error", diag)
+	}
+}
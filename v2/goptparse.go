@@ -1,9 +1,9 @@
 // This is free and unencumbered software released into the public domain.
 
 // Package optparse parses command line arguments very similarly to GNU
-// getopt_long(). It supports long options and optional arguments, but
-// does not permute arguments. It is intended as a replacement for Go's
-// flag package.
+// getopt_long(). It supports long options and optional arguments, and
+// only permutes arguments when asked to, through Config.Permute. It is
+// intended as a replacement for Go's flag package.
 //
 // To use, define your options as an Option slice and pass it, along
 // with the arguments string slice, to the Parse() function. It will
@@ -13,9 +13,16 @@ package v2
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -25,167 +32,2094 @@ const (
 	KindRequired
 	// KindOptional means the argument is optional
 	KindOptional
+)
 
+// The errors below are the possible values of Error.Message, and can
+// be checked for with errors.Is.
+var (
 	// ErrInvalid is used when an option is not recognized.
-	ErrInvalid = "invalid option"
+	ErrInvalid = errors.New("invalid option")
 	// ErrMissing is used when a required argument is missing.
-	ErrMissing = "option requires an argument"
+	ErrMissing = errors.New("option requires an argument")
 	// ErrTooMany is used when an unwanted argument is provided.
-	ErrTooMany = "option takes no arguments"
-	//ErrHelpRedefined is used when either -h or --help are
+	ErrTooMany = errors.New("option takes no arguments")
+	// ErrHelpRedefined is used when either -h or --help are
 	// redefined by the user.
-	ErrHelpRedefined = "cannot redefine --help or -h"
+	ErrHelpRedefined = errors.New("cannot redefine --help or -h")
 	// ErrHelpMissing is used when the Help field is omitted (or
-	// else intentionally provided as the empty string)
-	ErrHelpMissing = "missing help field"
+	// else intentionally provided as the empty string), and there
+	// is no HelpFunc either
+	ErrHelpMissing = errors.New("missing help field")
+	// ErrHelpRequested is used when --help or -h is given on the
+	// command line, after the help summary has been printed.
+	ErrHelpRequested = errors.New("help requested")
+	// ErrVersionRedefined is used when --version, or its
+	// configured short form, is redefined by the user while
+	// Config.Version is set.
+	ErrVersionRedefined = errors.New("cannot redefine --version")
+	// ErrVersionRequested is used when --version is given on the
+	// command line. This isn't an actual error, but a signal to
+	// exit the program.
+	ErrVersionRequested = errors.New("version requested")
+	// ErrCompletion is used when the hidden --_complete option
+	// starts the command line, after the completions have been
+	// printed. Like ErrVersionRequested, it's a signal to exit.
+	ErrCompletion = errors.New("completion requested")
+	// ErrAmbiguous is used when an abbreviated long option is a
+	// prefix of more than one long option.
+	ErrAmbiguous = errors.New("ambiguous option")
+	// ErrConflict is used when two options from the same
+	// exclusive group are both given.
+	ErrConflict = errors.New("conflicting options")
+	// ErrRequired is used when options marked as Required never
+	// appear on the command line.
+	ErrRequired = errors.New("missing required options")
+	// ErrDependency is used when an option is given without
+	// another option that it requires.
+	ErrDependency = errors.New("unmet dependency")
+	// ErrCommand is used when a subcommand is missing or not
+	// recognized.
+	ErrCommand = errors.New("unknown command")
+	// ErrValue is used when an option's Value rejects its
+	// argument.
+	ErrValue = errors.New("invalid argument")
+	// ErrChoice is used when an argument is not among an option's
+	// Choices.
+	ErrChoice = errors.New("invalid choice")
+	// ErrPattern is used when an argument doesn't match an
+	// option's Pattern.
+	ErrPattern = errors.New("argument does not match pattern")
+	// ErrBadPattern is used when an option's Pattern is not a
+	// valid regular expression.
+	ErrBadPattern = errors.New("invalid pattern")
+	// ErrArgCount is used when the remaining arguments don't fit
+	// the positionals declared in Config.Args.
+	ErrArgCount = errors.New("wrong number of arguments")
+	// ErrHandler is used when an option's Handler returns an
+	// error.
+	ErrHandler = errors.New("option handler failed")
+	// ErrInteger is used when an option with a Range is given an
+	// argument that isn't an integer.
+	ErrInteger = errors.New("invalid integer")
+	// ErrRange is used when an integer argument falls outside of
+	// an option's Range.
+	ErrRange = errors.New("argument out of range")
+	// ErrNoPath is used when the argument of an option with
+	// MustExist can't be found.
+	ErrNoPath = errors.New("path does not exist")
+	// ErrPathType is used when the argument of an option with
+	// MustExist names the wrong kind of file for its PathType.
+	ErrPathType = errors.New("path is of the wrong type")
+	// ErrKeyValue is used by KeyValues for an argument that isn't
+	// of the form key=value.
+	ErrKeyValue = errors.New("expected key=value")
+	// ErrDuplicate is used when two options share a long name,
+	// including aliases and the "no-" forms of Negatable options,
+	// or a short one.
+	ErrDuplicate = errors.New("duplicate option")
+	// ErrUnnamed is used by OptionBuilder for an option with
+	// neither a long nor a short form.
+	ErrUnnamed = errors.New("option has no name")
+	// ErrArgSetting is used when a KindNone option sets a field
+	// that only applies to arguments, such as Metavar or Default.
+	ErrArgSetting = errors.New("argument setting on an option that takes none")
+	// ErrTopic is used when --help is given a topic that isn't
+	// the Group of any option.
+	ErrTopic = errors.New("unknown help topic")
+	// ErrAttached is used when an option taking several
+	// arguments, through NArgs, or one with NoAttached, is given
+	// one attached to it.
+	ErrAttached = errors.New("option arguments must be separate")
+	// ErrNotNegatable is used when, with Config.PlusNegation, an
+	// option that takes an argument is given with a "+".
+	ErrNotNegatable = errors.New("option cannot be negated")
 )
 
 // Kind is an enumeration indicating how an option is used.
 type Kind int
 
-// Option represents a single argument. Unicode is fully supported, so a
-// short option may be any character. Using the zero value for Long
-// or Short means the option has form of that size. Kind must be one of
-// the constants.
-type Option struct {
-	Long  string
-	Short rune
-	Kind  Kind
-	Help  string
+// kindNames are the names of the kinds, as returned by String.
+var kindNames = map[Kind]string{
+	KindNone:     "none",
+	KindRequired: "required",
+	KindOptional: "optional",
+}
+
+// String returns the name of the kind, which is "none", "required", or
+// "optional". Any other value is shown as a number, as in "Kind(7)".
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Option represents a single argument. Unicode is fully supported, so a
+// short option may be any character. Using the zero value for Long
+// or Short means the option has form of that size. Kind must be one of
+// the constants.
+//
+// An argument is taken literally, even when it starts with a dash, as
+// in "--filter=-foo", "-f-foo", or, for KindRequired options only,
+// "--filter -foo". Since a KindOptional option never takes the next
+// argument, "--filter -foo" gives it no argument, and "-foo" is parsed
+// as options.
+type Option struct {
+	Long  string
+	Short rune
+	Kind  Kind
+	Help  string
+
+	// Default is the argument used when a KindOptional option is
+	// given without one. It can also be retrieved for absent
+	// options through the Optarg function.
+	Default string
+
+	// Required means the option itself must appear on the command
+	// line, or else parsing fails with ErrRequired. This is
+	// unrelated to KindRequired, which concerns its argument.
+	Required bool
+
+	// Env names an environment variable to fall back on. If the
+	// option doesn't appear on the command line but the variable
+	// is set, a Result is synthesized with the variable's value
+	// as its argument, as though the option had been given last.
+	// For a KindNone option, the value is a boolean, as accepted
+	// by strconv.ParseBool: a true one gives the option, and a
+	// false or empty one leaves it out, or gives it Negated if
+	// it's Negatable. Any other value fails with ErrValue.
+	Env string
+
+	// Metavar names the option's argument in help output and
+	// usage synopses, as in "--output=FILE". When empty, the long
+	// name in upper case is used, or ARG for short-only options.
+	Metavar string
+
+	// Value, if not nil, receives the option's argument through
+	// its Set method each time the option is parsed, much like a
+	// flag.Value. The argument is empty for KindNone options. If
+	// Set returns an error, parsing fails with ErrValue.
+	Value Value
+
+	// Negatable registers an additional "--no-" form of the long
+	// option, as in "--no-color" for "--color", producing a Result
+	// with Negated set. The negated form never takes an argument.
+	// The short form is unaffected.
+	Negatable bool
+
+	// Choices, if not nil, lists the only arguments the option
+	// accepts. They are matched exactly, so the comparison is case
+	// sensitive. Any other argument fails with ErrChoice.
+	Choices []string
+
+	// Aliases are additional long names for the option, as in
+	// "--colour" for "--color". They are only recognized when Long
+	// is set, and a Result always reports the option by its Long.
+	Aliases []string
+
+	// Hidden keeps the option out of the help summary and usage
+	// synopsis, which is useful for experimental or internal
+	// options. It is parsed like any other option, except that it
+	// must be given by its full name, and is never suggested in
+	// error messages.
+	Hidden bool
+
+	// Deprecated marks the option as deprecated when not empty.
+	// The option still works, but the first time it's parsed, a
+	// warning is written that includes this text, which should
+	// point to a replacement, as in "use --new-flag instead".
+	Deprecated string
+
+	// Pattern, if not empty, is a regular expression that the
+	// option's argument must match, as in "^[a-z0-9-]+$" for a
+	// tag. It is unanchored, as with regexp.MatchString. Any other
+	// argument fails with ErrPattern.
+	Pattern string
+
+	// Handler, if not nil, is called with the option's argument as
+	// soon as the option is parsed, after any Value, so handlers
+	// run in command line order. The argument is empty for
+	// KindNone options. If Handler returns an error, parsing fails
+	// with ErrHandler.
+	Handler func(optarg string) error
+
+	// Range, if not nil, restricts the option's argument to
+	// base-10 integers within it. Other arguments fail with
+	// ErrInteger, and integers outside of it with ErrRange.
+	Range *Range
+
+	// MustExist requires the option's argument to be the path of
+	// an existing file, or else parsing fails with ErrNoPath.
+	MustExist bool
+
+	// PathType further restricts the kind of file that a MustExist
+	// option names, failing with ErrPathType otherwise.
+	PathType PathType
+
+	// NArgs is the number of arguments a KindRequired option
+	// takes, as in two for "--point X Y". They are always given
+	// as separate arguments, so an attached one, as in "--point=X"
+	// or "-pX", fails with ErrAttached, and fewer than NArgs fail
+	// with ErrMissing. The arguments are collected into the
+	// Result's Optargs, and are validated, and passed to any Value
+	// and Handler, one at a time. Zero means one, as usual.
+	NArgs int
+
+	// NoAttached makes a KindRequired option take its argument
+	// only as the following one, as in "--password secret", so
+	// that "--password=secret" or "-psecret" fails with
+	// ErrAttached. This keeps secrets out of logs that record
+	// arguments up to an "=", and the like.
+	NoAttached bool
+
+	// Example, if not empty, shows how the option is used, as in
+	// "--output build/out.bin". The default help summary lists it
+	// under the option's description, as "Example: ...".
+	Example string
+
+	// Group names the topic of the help summary the option falls
+	// under, as in "network". When any option has a Group, --help
+	// takes an optional argument, as in "--help=network", to show
+	// only the options of that group. Since the argument may be
+	// attached to -h, "-hv" then asks for the "v" topic.
+	Group string
+
+	// Complete, if not nil, lists the possible arguments of the
+	// option that begin with the given prefix, for shell
+	// completion of values that depend on the program's state,
+	// such as the branches of a repository. When any option has
+	// Complete, a command line starting with the hidden
+	// --_complete option, followed by the words typed so far,
+	// prints the completions of the last word, one per line,
+	// and stops parsing with ErrCompletion. The scripts from
+	// GenBashCompletion use it.
+	Complete func(prefix string) []string
+
+	// HelpFunc, if not nil, computes the option's help text when
+	// Help is empty, for descriptions that are expensive to build
+	// or mention values only known at run time. It is only called
+	// when the text is rendered, as for --help, and Help, when
+	// set, takes precedence. An option with a HelpFunc satisfies
+	// the check for a missing Help. A HelpFormatter is given the
+	// option as it is, and may call HelpFunc itself.
+	HelpFunc func() string
+
+	// Normalize, if not nil, canonicalizes each argument of the
+	// option, as by lowercasing it, trimming it, or expanding a
+	// leading "~" to the home directory. The argument is replaced
+	// with the result, both in the Result's Optarg and Optargs
+	// and for validation, which comes after, so Choices and the
+	// like apply to the normalized argument. Arguments from the
+	// environment or a config file are normalized as well, but
+	// empty ones are left alone.
+	Normalize func(string) string
+}
+
+// PathType is the kind of file that an option's argument must name.
+type PathType int
+
+const (
+	// PathAny allows any kind of file.
+	PathAny PathType = iota
+	// PathFile allows only regular files.
+	PathFile
+	// PathDir allows only directories.
+	PathDir
+)
+
+// Range is an inclusive range of integers, as in 1 to 64 for a
+// --threads option.
+type Range struct {
+	Min int
+	Max int
+}
+
+// Value is the interface to the dynamic value stored in an option. It
+// is the same as flag.Value, so types written for the flag package
+// can be used as is.
+type Value interface {
+	String() string
+	Set(string) error
+}
+
+// Error represents all possible parsing errors. It embeds the option
+// that has been misused, and Message is one of the error values
+// above. Implements error, and matches its Message under errors.Is.
+//
+// The remaining fields carry extra detail for some kinds of errors,
+// and are otherwise left empty.
+type Error struct {
+	Option
+	Message error
+
+	// Candidates holds the long options that an ErrAmbiguous
+	// abbreviation could have meant, the arguments allowed for
+	// ErrChoice, or the help topics for ErrTopic.
+	Candidates []string
+
+	// Other is the second option involved in an ErrConflict or
+	// ErrDependency.
+	Other Option
+
+	// Missing holds every required option that was absent for
+	// ErrRequired. The embedded option is the first of them.
+	Missing []Option
+
+	// Command is the unrecognized subcommand for ErrCommand, or
+	// empty if none was given.
+	Command string
+
+	// Err is the error returned by the option's Value for
+	// ErrValue, by the option's Handler for ErrHandler, by the
+	// regexp package for ErrBadPattern, or by os.Stat for
+	// ErrNoPath. For ErrArgCount, it describes the expected and
+	// actual counts, and for ErrArgSetting, the offending field.
+	Err error
+
+	// Suggestion is the long name of a known option spelled
+	// similarly to an ErrInvalid long option, if there is one.
+	Suggestion string
+
+	// Optarg is the rejected argument for ErrChoice, ErrPattern,
+	// ErrInteger, ErrRange, ErrPathType, ErrKeyValue, and
+	// ErrTopic.
+	Optarg string
+
+	// Token is the command line argument being parsed when the
+	// error occurred, exactly as given, as in "--verbsoe" or
+	// "-xvf". It is set for every failure of an option found on
+	// the command line, including those rejecting its argument,
+	// but not for options taken from Env variables or the
+	// ConfigFile, for errors involving several options, such as
+	// ErrConflict, nor for ErrHelpRequested and ErrVersionRequested.
+	Token string
+
+	// Index is the position of Token among the arguments, where
+	// the program name is at 0, so that a token given more than
+	// once can be told apart. It is 0 when Token isn't set.
+	Index int
+}
+
+// computeFlagDesc computes the beginning of a flag's cli help text based on
+// which formats are defined for that flag, and on whether it takes an
+// argument.
+func computeFlagDesc(option Option) string {
+	var longArg, shortArg string
+	switch option.Kind {
+	case KindRequired:
+		longArg = "=" + metavar(option)
+		shortArg = " " + metavar(option)
+	case KindOptional:
+		longArg = "[=" + metavar(option) + "]"
+		shortArg = "[" + metavar(option) + "]"
+	}
+
+	names := option.longNames()
+	for i := range names {
+		if option.Negatable {
+			names[i] = "[no-]" + names[i]
+		}
+	}
+	long := strings.Join(names, ", --")
+
+	if option.Long != "" && option.Short != 0 {
+		return fmt.Sprintf("--%s%s (-%c%s)", long, longArg, option.Short, shortArg)
+	} else if option.Long != "" {
+		return fmt.Sprintf("--%s%s", long, longArg)
+	} else {
+		return fmt.Sprintf("-%c%s", option.Short, shortArg)
+	}
+}
+
+// Is reports whether target is the error's Message, so that errors.Is
+// can classify an Error by its category.
+func (e Error) Is(target error) bool {
+	return target == e.Message
+}
+
+// Unwrap returns the underlying cause of the error, if any, which is
+// the error returned by a Value for ErrValue, by a Handler for
+// ErrHandler, the regexp syntax error for ErrBadPattern, or the
+// os.Stat error for ErrNoPath.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// longNames returns the long name of the option followed by its
+// aliases, or nil if it has no long form.
+func (o Option) longNames() []string {
+	if o.Long == "" {
+		return nil
+	}
+	return append([]string{o.Long}, o.Aliases...)
+}
+
+// helpText returns the option's Help, or the text computed by its
+// HelpFunc when Help is empty.
+func (o Option) helpText() string {
+	if o.Help == "" && o.HelpFunc != nil {
+		return o.HelpFunc()
+	}
+	return o.Help
+}
+
+// name returns the option as it would be written on the command line,
+// mentioning both forms when both are defined.
+func (o Option) name() string {
+	if o.Long != "" && o.Short != 0 {
+		return fmt.Sprintf("--%s (-%c)", o.Long, o.Short)
+	} else if o.Long != "" {
+		return fmt.Sprintf("--%s", o.Long)
+	} else {
+		return fmt.Sprintf("-%c", o.Short)
+	}
+}
+
+// Messages, if not nil, overrides the text of the error categories
+// above when an Error is rendered, so that messages can be translated.
+// It maps each category, such as ErrInvalid, to its text. The rest of
+// the message, such as the option's name, is rendered as usual, and
+// errors.Is is unaffected. It should be set before parsing begins.
+//
+// Only the category is replaced. The English wording that some
+// messages add after it stays as it is, such as "did you mean",
+// "could be", "choose from", "must match", "must be from", and "is
+// not a directory", as do "missing command" and the whole of an
+// ErrArgCount message, as in "expected at least 2 arguments". A
+// program needing those translated can build its own message from
+// the fields of Error instead.
+var Messages map[error]string
+
+// text returns the text of the error's category, as overridden by
+// Messages.
+func (e Error) text() string {
+	if text, ok := Messages[e.Message]; ok {
+		return text
+	} else if e.Message == nil {
+		return ""
+	}
+	return e.Message.Error()
+}
+
+func (e Error) Error() string {
+	msg := e.text()
+	if e.Message == ErrArgCount {
+		return e.Err.Error()
+	} else if e.Message == ErrUnnamed {
+		return msg
+	} else if e.Message == ErrCommand {
+		if e.Command == "" {
+			return "missing command"
+		}
+		return fmt.Sprintf("%s: %s", msg, e.Command)
+	} else if e.Message == ErrChoice {
+		return fmt.Sprintf("%s for %s: %q (choose from %s)",
+			msg, e.name(), e.Optarg, strings.Join(e.Candidates, ", "))
+	} else if e.Message == ErrTopic {
+		return fmt.Sprintf("%s: %q (choose from %s)",
+			msg, e.Optarg, strings.Join(e.Candidates, ", "))
+	} else if len(e.Candidates) > 0 {
+		return fmt.Sprintf("%s: --%s (could be --%s)",
+			msg, e.Long, strings.Join(e.Candidates, ", --"))
+	} else if e.Message == ErrConflict {
+		return fmt.Sprintf("%s: %s and %s", msg, e.name(), e.Other.name())
+	} else if e.Message == ErrDependency {
+		return fmt.Sprintf("%s: %s requires %s", msg, e.name(), e.Other.name())
+	} else if e.Suggestion != "" {
+		return fmt.Sprintf("%s: %s (did you mean --%s?)", msg, e.name(), e.Suggestion)
+	} else if e.Message == ErrPattern {
+		return fmt.Sprintf("%s for %s: %q (must match %s)",
+			msg, e.name(), e.Optarg, e.Pattern)
+	} else if e.Message == ErrInteger || e.Message == ErrKeyValue {
+		return fmt.Sprintf("%s for %s: %q", msg, e.name(), e.Optarg)
+	} else if e.Message == ErrRange {
+		return fmt.Sprintf("%s for %s: %s (must be from %d to %d)",
+			msg, e.name(), e.Optarg, e.Range.Min, e.Range.Max)
+	} else if e.Message == ErrPathType {
+		want := "a regular file"
+		if e.PathType == PathDir {
+			want = "a directory"
+		}
+		return fmt.Sprintf("%s for %s: %q is not %s", msg, e.name(), e.Optarg, want)
+	} else if e.Message == ErrValue || e.Message == ErrHandler || e.Message == ErrNoPath ||
+		e.Message == ErrBadPattern || e.Message == ErrArgSetting {
+		return fmt.Sprintf("%s for %s: %v", msg, e.name(), e.Err)
+	} else if len(e.Missing) > 0 {
+		names := make([]string, len(e.Missing))
+		for i, option := range e.Missing {
+			names[i] = option.name()
+		}
+		return fmt.Sprintf("%s: %s", msg, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("%s: %s", msg, e.name())
+}
+
+// Result is an individual successfully-parsed option. It embeds the
+// original Option plus any argument. For options with optional
+// arguments (KindOptional), Optarg alone can't tell an empty supplied
+// argument from no argument supplied, as with "--pager=" and "--pager".
+// Similarly, when the option has a Default, it replaces both. HasArg
+// tells them apart.
+//
+// Negated is true when a Negatable option was given in its "--no-"
+// form, or a KindNone option was given as "+x" under
+// Config.PlusNegation, in which case Optarg is always empty.
+//
+// HasArg is true when an argument was supplied, which is always the
+// case for KindRequired options, and never for KindNone ones. For
+// KindOptional options, it means the argument was attached, as in
+// "--pager=" or "-Pless". Arguments taken from the environment or a
+// config file count as supplied.
+type Result struct {
+	Option
+	Optarg  string
+	Negated bool
+	HasArg  bool
+
+	// Optargs holds every argument of an option with NArgs
+	// greater than one, in order. Optarg is then the first.
+	Optargs []string
+}
+
+// Int parses Optarg as a base-10 integer.
+func (r Result) Int() (int, error) {
+	n, err := strconv.Atoi(r.Optarg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer argument for %s: %w", r.name(), err)
+	}
+	return n, nil
+}
+
+// Float64 parses Optarg as a floating-point number.
+func (r Result) Float64() (float64, error) {
+	f, err := strconv.ParseFloat(r.Optarg, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number argument for %s: %w", r.name(), err)
+	}
+	return f, nil
+}
+
+// Duration parses Optarg as a time.Duration, such as "30s" or "1h30m",
+// accepting the same values as time.ParseDuration.
+func (r Result) Duration() (time.Duration, error) {
+	d, err := time.ParseDuration(r.Optarg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration argument for %s: %w", r.name(), err)
+	}
+	return d, nil
+}
+
+// Bool parses Optarg as a boolean, accepting the same values as
+// strconv.ParseBool. As exceptions, a negated result is always false,
+// and a KindNone option, which never has an argument, is always true.
+func (r Result) Bool() (bool, error) {
+	if r.Negated {
+		return false, nil
+	} else if r.Kind == KindNone {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(r.Optarg)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean argument for %s: %w", r.name(), err)
+	}
+	return b, nil
+}
+
+// Parse results a slice of the parsed results, the remaining arguments,
+// and the first parser error. The results slice always contains results
+// up until the first error.
+//
+// The first argument, args[0], is skipped, and arguments are not
+// permuted (see Config.Permute for that). Parsing stops at the first
+// non-option argument, or "--".
+// The latter is not included in the remaining, unparsed arguments.
+// Empty or nil args, lacking even the program name, are taken as a
+// command line without arguments.
+//
+// goptparse: If --help or -h is given on the command line, a help
+// summary of all commands is printed to standard output, and the
+// calling program is instructed to exit. Redefining either --help or
+// -h is illegal, to avoid confusing scenarios. The program also exits
+// after printing completions for --_complete (see Option.Complete).
+func Parse(options []Option, args []string) ([]Result, []string, error) {
+	results, rest, err := ParseWithOutput(options, args, os.Stdout)
+
+	if errors.Is(err, ErrHelpRequested) || errors.Is(err, ErrCompletion) {
+		os.Exit(0)
+	}
+
+	return results, rest, err
+}
+
+// ParseOSArgs is like Parse, with the program's own command line,
+// os.Args, as the arguments. As always, os.Args[0], the program name,
+// is skipped.
+func ParseOSArgs(options []Option) ([]Result, []string, error) {
+	return Parse(options, os.Args)
+}
+
+// ParseWithOutput is like Parse, but the help summary is written to w
+// instead of standard output.
+//
+// Unlike Parse, ParseWithOutput never exits the program. When --help
+// or -h is given, it returns an Error whose Message is
+// ErrHelpRequested, along with the results parsed so far, and leaves
+// it to the caller to decide what to do next.
+func ParseWithOutput(options []Option, args []string, w io.Writer) ([]Result, []string, error) {
+	return ParseConfig(options, args, Config{Output: w})
+}
+
+// Config holds optional settings that alter how Parse behaves. The
+// zero value for Config gives the same behavior as ParseWithOutput
+// writing to standard output.
+type Config struct {
+	// Output is where the help summary is written. When nil,
+	// standard output is used. The summary is wrapped to the
+	// width given by the COLUMNS environment variable when Output
+	// is a terminal, and to 80 columns otherwise.
+	Output io.Writer
+
+	// Warnings is where warnings, such as for deprecated options,
+	// are written. When nil, standard error is used.
+	Warnings io.Writer
+
+	// Permute enables GNU-style argument permutation. Instead of
+	// stopping at the first non-option argument, the parser sets
+	// it aside and keeps looking for options. The set-aside
+	// arguments are returned, in order, at the front of the
+	// remaining arguments. A "--" still stops parsing outright.
+	//
+	// As with GNU getopt, permutation is turned back off when the
+	// POSIXLY_CORRECT environment variable is set, to any value,
+	// for users who expect parsing to stop at the first non-option
+	// argument.
+	Permute bool
+
+	// Exclusive lists groups of mutually exclusive options, each
+	// given by the long names of its members. If more than one
+	// member of a group appears on the command line, in any
+	// order, parsing fails with ErrConflict. Options taken from
+	// Env variables or the ConfigFile are left out when another
+	// member of their group was given on the command line.
+	Exclusive [][]string
+
+	// Requires maps the long name of an option to the long names
+	// of the options it depends on. If an option is given without
+	// all of its dependencies, parsing fails with ErrDependency.
+	Requires map[string][]string
+
+	// NegativeNumbers makes arguments that look like negative
+	// numbers, such as "-5" or "-3.14", count as non-option
+	// arguments, unless their first digit is defined as a short
+	// option.
+	NegativeNumbers bool
+
+	// HelpFormatter renders the help summary written when --help
+	// or -h is given. When nil, FormatHelp is used, styled according
+	// to HelpColor.
+	HelpFormatter HelpFormatter
+
+	// NoHelp turns off the injected --help option, leaving --help
+	// and -h free to be defined like any other option.
+	NoHelp bool
+
+	// HelpShort moves the short form of the injected --help option
+	// from -h to another character, freeing -h for other uses. A
+	// negative value leaves --help without a short form.
+	HelpShort rune
+
+	// Version, if not empty, injects a --version option, which
+	// writes Version to Output and stops parsing with
+	// ErrVersionRequested, much like --help does.
+	Version string
+
+	// VersionShort gives the injected --version option a short
+	// form, conventionally 'V'. There is none by default.
+	VersionShort rune
+
+	// Args, if not nil, declares the positional arguments that may
+	// remain after the options. If there are too few or too many,
+	// parsing fails with ErrArgCount. Use Positionals to access
+	// them by name. Those with Help are listed after the options
+	// in the default help summary, unless a help topic is asked
+	// for.
+	Args []ArgSpec
+
+	// ConfigFile, if not empty, is the path of a JSON file holding
+	// an object whose keys are long option names. Options not
+	// given on the command line, nor through their Env variable,
+	// take their arguments from it, as though given last. KindNone
+	// options take a boolean, and others a string, number, or
+	// boolean. Keys that aren't options are reported to Warnings,
+	// while a missing or malformed file fails parsing.
+	ConfigFile string
+
+	// SingleDashLong lets long options be given with a single
+	// dash, as in "-verbose", like the flag package allows. An
+	// argument such as "-vf" is still taken as a cluster of short
+	// options if it is a valid one, that is, if each of its
+	// characters is a short option, up to the first one taking an
+	// argument. Otherwise, it's taken as a long option if it names
+	// one, including by abbreviation, and as a cluster of short
+	// options, which fails with ErrInvalid, if not.
+	SingleDashLong bool
+
+	// HelpColor controls whether the default help summary shows
+	// option names in bold, using ANSI escape sequences. It has no
+	// effect on a HelpFormatter.
+	HelpColor ColorMode
+
+	// HelpFlagWidth sets the width of the left column of the
+	// default help summary, which holds the flag descriptors. The
+	// descriptions start two columns past it. A descriptor too
+	// long for its column gets a line to itself, with its
+	// description below, lined up with the others. When zero, the
+	// column is as wide as the longest descriptor.
+	HelpFlagWidth int
+
+	// HelpDescWidth is the width that each line of a description
+	// is padded to in the default help summary. When zero, it is
+	// 50 columns.
+	HelpDescWidth int
+
+	// ShowDefaults makes the default help summary end the
+	// description of each option that has a Default with it, as
+	// in "(default: auto)".
+	ShowDefaults bool
+
+	// Terminator, if not empty, is an argument that ends the
+	// options just like "--", which keeps working. This suits
+	// tools embedding another command line, as in "END". Like
+	// "--", it is consumed, and is not part of the remaining
+	// arguments.
+	Terminator string
+
+	// StdinArgs makes a "-" at the end of the remaining arguments
+	// stand for more arguments, read from Stdin one per line, as
+	// with xargs. Empty lines are skipped. The arguments read
+	// replace the "-", and are not parsed for options.
+	StdinArgs bool
+
+	// Stdin is where StdinArgs reads from. When nil, standard
+	// input is used.
+	Stdin io.Reader
+
+	// IgnoreUnknown passes unrecognized options through to the
+	// remaining arguments, in order, instead of failing with
+	// ErrInvalid, and parsing continues past them. This suits
+	// wrappers that forward unknown options to another program.
+	// Since the arguments an unknown option might take can't be
+	// known, only the option itself is passed through, as in
+	// "--depth=1" or "--depth", and any separate argument is then
+	// parsed like any other. An unknown short option passes
+	// through along with the rest of its cluster, so "-vZq",
+	// where only v is known, passes "-Zq".
+	IgnoreUnknown bool
+
+	// StopWhen, if not nil, is called with each option as it is
+	// parsed. When it returns true, parsing stops right after
+	// that option, as though the options had run out, and the
+	// arguments that follow are left among the remaining ones,
+	// as for a --interactive option that hands them to a
+	// subshell. Within a cluster of short options, the rest of
+	// the cluster is left as well, so stopping at i in "-iv"
+	// leaves "-v".
+	StopWhen func(Result) bool
+
+	// LenientEquals forgives spaces around the "=" of a long
+	// option that takes an argument, as often creep into pasted
+	// commands: "--output = file" and "--output =file" are both
+	// taken as "--output=file". By default, the "=" or "=file"
+	// would be the option's argument, or a non-option argument
+	// for a KindOptional option.
+	LenientEquals bool
+
+	// Prefix, if not empty, replaces the dashes that introduce
+	// options, for porting tools with other conventions, such as
+	// "/" for DOS-style options. Options then come one to an
+	// argument, as in "/v /output:file": a name of one character
+	// is tried as a short option first, and any other name as a
+	// long option, abbreviations included. There are no clusters,
+	// and arguments starting with a dash are non-option arguments,
+	// though "--" still ends the options. The help summary keeps
+	// showing the usual dashes.
+	Prefix string
+
+	// Separator, if not empty, replaces the "=" that attaches an
+	// argument to a long option, or to any option given with
+	// Prefix, as in ":" for "/output:file".
+	Separator string
+
+	// WarnLateOptions writes a warning to Warnings when, without
+	// permutation, parsing stops at a non-option argument that is
+	// followed by one that looks like an option, as for "-x" in
+	// "-v file -x", which is left among the remaining arguments.
+	// Only the first such argument is reported, and none past a
+	// "--".
+	WarnLateOptions bool
+
+	// PlusNegation lets KindNone short options be given with a
+	// "+" instead of a "-", to turn off what they turn on, as with
+	// the shell's "set +x". The Result is then Negated. As with
+	// "-", the options may be clustered, so "+xv" negates both x
+	// and v. An option that takes an argument can't be negated,
+	// and fails with ErrNotNegatable. A lone "+" is a non-option
+	// argument. PlusNegation has no effect along with Prefix.
+	PlusNegation bool
+
+	// LazyOptional changes how a KindOptional short option takes
+	// the rest of its cluster. By default, it greedily takes it
+	// as its argument, as getopt does, so "-On" gives O the
+	// argument "n", even if n is an option. With LazyOptional,
+	// the rest of the cluster is parsed as further options
+	// instead, when it is a valid cluster of them, leaving O with
+	// no argument. So "-On" and "-Onx" are O followed by n, and x,
+	// when those are options, while "-O2" still gives O the
+	// argument "2", as does "-Onz" if z isn't an option. An
+	// argument attached with "=", as in "-O=n", is always taken.
+	LazyOptional bool
+}
+
+// ColorMode selects whether output is styled with ANSI escape
+// sequences.
+type ColorMode int
+
+const (
+	// ColorAuto styles output written to a terminal, unless the
+	// NO_COLOR environment variable is set to a non-empty value.
+	ColorAuto ColorMode = iota
+	// ColorAlways always styles output.
+	ColorAlways
+	// ColorNever never styles output.
+	ColorNever
+)
+
+// enabled reports whether output written to w should be styled.
+func (c ColorMode) enabled(w io.Writer) bool {
+	switch c {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// isTerminal reports whether w is a terminal, rather than a pipe, a
+// file, or a writer of some other kind.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// HelpFormatter renders the help summary for a set of options, which
+// includes the injected --help option itself.
+type HelpFormatter func(options []Option) string
+
+// ParseConfig is like ParseWithOutput, but its behavior is controlled
+// by config.
+//
+// Each call works on its own copy of the options, so the Parse
+// functions may be called from several goroutines at once with the
+// same options, provided that any Value and Handler in them are safe
+// for concurrent use, and that the options aren't modified meanwhile.
+func ParseConfig(options []Option, args []string, config Config) ([]Result, []string, error) {
+	results, rest, errs := parseArgs(options, args, config, false)
+	if len(errs) > 0 {
+		return results, rest, errs[0]
+	}
+	return results, rest, nil
+}
+
+// ParseAll is like ParseWithOutput writing to standard output, but
+// instead of stopping at the first parser error, it skips the
+// offending argument and keeps going, so that every mistake can be
+// reported at once. The errors are returned in the order they were
+// found.
+//
+// Errors in the option definitions themselves, as well as a request
+// for help, still stop parsing immediately.
+func ParseAll(options []Option, args []string) ([]Result, []string, []error) {
+	return parseArgs(options, args, Config{}, true)
+}
+
+// ParseRaw is a low-level alternative to ParseWithOutput that does no
+// more than parse: no --help option is injected, options may have an
+// empty Help, and nothing is ever written. It suits programs that
+// provide their own help, such as REPLs.
+func ParseRaw(options []Option, args []string) ([]Result, []string, error) {
+	p := Parser{
+		Config: Config{NoHelp: true, Output: io.Discard, Warnings: io.Discard},
+		raw:    true,
+	}
+	if err := p.Init(options); err != nil {
+		return []Result{}, []string{}, err
+	}
+	results, rest, errs := p.parse(args, false)
+	if len(errs) > 0 {
+		return results, rest, errs[0]
+	}
+	return results, rest, nil
+}
+
+// Validate checks args against options as Parse would, returning the
+// first error, but without side effects: nothing is written, and no
+// Value or Handler is called. A request for help or the version is not
+// an error. It is meant for checking command lines ahead of time, such
+// as those supplied by users of a program.
+func Validate(options []Option, args []string) error {
+	if errs := ValidateConfig(options, args, Config{}); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateConfig is like Validate, but parsing is controlled by config,
+// and like ParseAll, every error is returned rather than just the
+// first. The Output and Warnings of config are ignored.
+func ValidateConfig(options []Option, args []string, config Config) []error {
+	inert := make([]Option, len(options))
+	for i, option := range options {
+		option.Value = nil
+		option.Handler = nil
+		inert[i] = option
+	}
+	config.Output = io.Discard
+	config.Warnings = io.Discard
+
+	_, _, errs := parseArgs(inert, args, config, true)
+	var found []error
+	for _, err := range errs {
+		if !errors.Is(err, ErrHelpRequested) && !errors.Is(err, ErrVersionRequested) {
+			found = append(found, err)
+		}
+	}
+	return found
+}
+
+// helpOption is the option that the Parse functions add to every set
+// of options.
+var helpOption = Option{Long: "help", Short: 'h', Kind: KindNone, Help: "Print this help message"}
+
+// helpOption returns the option to inject as --help, with its short
+// form as configured by HelpShort.
+func (c Config) helpOption() Option {
+	help := helpOption
+	if c.HelpShort > 0 {
+		help.Short = c.HelpShort
+	} else if c.HelpShort < 0 {
+		help.Short = 0
+	}
+	return help
+}
+
+// versionOption returns the option to inject as --version.
+func (c Config) versionOption() Option {
+	return Option{Long: "version", Short: c.VersionShort, Kind: KindNone, Help: "Print version information"}
+}
+
+// parseArgs does the work behind ParseConfig and ParseAll. When
+// keepGoing is false, parsing stops at the first error.
+func parseArgs(options []Option, args []string, config Config, keepGoing bool) ([]Result, []string, []error) {
+	p := Parser{Config: config}
+	if err := p.Init(options); err != nil {
+		return []Result{}, []string{}, []error{err}
+	}
+	return p.parse(args, keepGoing)
+}
+
+// Parser is a reusable alternative to the Parse functions, meant for
+// programs that parse many command lines against the same options,
+// such as servers. The options are validated and indexed once by Init,
+// after which Parse may be called any number of times. The embedded
+// Config may be changed between calls to Parse.
+//
+// The zero value for Parser is ready to use, and behaves as though
+// Init had been called with no options.
+//
+// Init copies the options, so changes to the caller's slice don't
+// affect the parser afterwards. A Parser keeps the state of the
+// command line being parsed, however, so it must not be used from
+// several goroutines at once; give each goroutine its own.
+type Parser struct {
+	Config
+
+	// captured holds the options shown in the help summary.
+	captured []Option
+	// patterns holds each option's Pattern, compiled.
+	patterns map[string]*regexp.Regexp
+	// help records whether Init injected the --help option.
+	help bool
+	// topics holds the Group of every option shown in the help
+	// summary, once each, in the order they were defined.
+	topics []string
+	// raw allows options without Help, for ParseRaw.
+	raw bool
+	// version is the version printed for --version, or empty if
+	// Init didn't inject it.
+	version string
+	// completes records whether any option has Complete, which
+	// enables --_complete.
+	completes bool
+	// tokens, when not nil, collects the options and positionals
+	// in command line order, for ParseOrdered.
+	tokens *[]Token
+	parser parser
+	err    error
+}
+
+// Init prepares the parser to recognize options, along with the
+// injected --help and --version options. It returns the same errors
+// that the Parse functions do for invalid option definitions; Parse
+// keeps returning that error until Init succeeds.
+//
+// Unlike the rest of the Config, NoHelp, HelpShort, Version, and
+// VersionShort take effect when Init is called, rather than on each
+// call to Parse.
+func (p *Parser) Init(options []Option) error {
+	p.captured = nil
+	p.patterns = nil
+	p.topics = nil
+	p.completes = false
+	p.help = !p.NoHelp
+	p.version = p.Version
+	p.parser = parser{}
+	help := p.helpOption()
+	version := p.versionOption()
+
+	// Used to capture user-defined options, to extract help info
+	// later.
+	capturedOptions := make([]Option, 0, len(options)+2)
+
+	// Used to catch options defined twice, which would otherwise
+	// be shadowed by the first definition.
+	longs := make(map[string]bool, len(options))
+	shorts := make(map[rune]bool, len(options))
+
+	for _, option := range options {
+		// Aliases count too, since they would take over the
+		// names just as well.
+		names := option.longNames()
+		if p.help && (contains(names, help.Long) || (help.Short != 0 && option.Short == help.Short)) {
+			p.err = Error{Option: Option{Long: help.Long, Short: help.Short}, Message: ErrHelpRedefined}
+			return p.err
+		}
+		if p.version != "" && (contains(names, version.Long) || (version.Short != 0 && option.Short == version.Short)) {
+			p.err = Error{Option: Option{Long: version.Long, Short: version.Short}, Message: ErrVersionRedefined}
+			return p.err
+		}
+
+		// The "no-" forms of a Negatable option are names of its
+		// own, and would hide an option defined with them.
+		taken := names
+		if option.Negatable {
+			taken = make([]string, 0, 2*len(names))
+			for _, name := range names {
+				taken = append(taken, name, "no-"+name)
+			}
+		}
+		for _, name := range taken {
+			if longs[name] {
+				p.err = Error{Option: Option{Long: name}, Message: ErrDuplicate}
+				return p.err
+			}
+			longs[name] = true
+		}
+		if option.Short != 0 {
+			if shorts[option.Short] {
+				p.err = Error{Option: Option{Short: option.Short}, Message: ErrDuplicate}
+				return p.err
+			}
+			shorts[option.Short] = true
+		}
+
+		// Ensure that the Help field isn't the empty
+		// string. This is mainly to ensure that the user
+		// doesn't forget to add the field in the first place.
+		if option.Help == "" && option.HelpFunc == nil && !p.raw {
+			p.err = Error{Option: option, Message: ErrHelpMissing}
+			return p.err
+		}
+
+		re, err := checkOption(option)
+		if err != nil {
+			p.err = err
+			return p.err
+		}
+		if re != nil {
+			if p.patterns == nil {
+				p.patterns = make(map[string]*regexp.Regexp)
+			}
+			p.patterns[option.Pattern] = re
+		}
+		if option.Complete != nil {
+			p.completes = true
+		}
+
+		// Capture the given option, for use in the help info
+		// display, unless it's meant to stay out of sight.
+		if !option.Hidden {
+			capturedOptions = append(capturedOptions, option)
+			if option.Group != "" && !contains(p.topics, option.Group) {
+				p.topics = append(p.topics, option.Group)
+			}
+		}
+	}
+
+	// Here is where we add the "help" option.
+	//
+	// It needs to be added to both the options slice (so that
+	// it's usable!), and to the 'capturedOptions' slice (so that
+	// its own help documentation shows up among the output of
+	// --help itself.) The options are copied first, since
+	// appending to the caller's slice could overwrite whatever
+	// lies past its end in the backing array.
+	options = append(make([]Option, 0, len(options)+2), options...)
+	if p.version != "" {
+		options = append(options, version)
+		capturedOptions = append(capturedOptions, version)
+	}
+	if p.help {
+		if p.topics != nil {
+			help.Kind = KindOptional
+			help.Metavar = "TOPIC"
+		}
+		options = append(options, help)
+		capturedOptions = append(capturedOptions, help)
+	}
+
+	p.captured = capturedOptions
+	p.parser = *newParser(options, nil)
+	p.err = nil
+	return nil
+}
+
+// checkOption checks the settings of option that Init rejects, other
+// than its names and Help, failing with ErrArgSetting or ErrBadPattern.
+// It returns the compiled Pattern, if any.
+func checkOption(option Option) (*regexp.Regexp, error) {
+	if field := argSetting(option); field != "" {
+		return nil, Error{Option: option, Message: ErrArgSetting, Err: fmt.Errorf("%s is set", field)}
+	}
+	if option.Pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(option.Pattern)
+	if err != nil {
+		return nil, Error{Option: option, Message: ErrBadPattern, Err: err}
+	}
+	return re, nil
+}
+
+// argSetting returns the name of a field of a KindNone option that
+// only makes sense for options taking an argument, if any is set.
+func argSetting(option Option) string {
+	if option.Kind != KindNone {
+		return ""
+	}
+	switch {
+	case option.Metavar != "":
+		return "Metavar"
+	case option.Default != "":
+		return "Default"
+	case option.Choices != nil:
+		return "Choices"
+	case option.Pattern != "":
+		return "Pattern"
+	case option.Range != nil:
+		return "Range"
+	case option.MustExist:
+		return "MustExist"
+	case option.NArgs != 0:
+		return "NArgs"
+	case option.NoAttached:
+		return "NoAttached"
+	case option.Complete != nil:
+		return "Complete"
+	case option.Normalize != nil:
+		return "Normalize"
+	}
+	return ""
+}
+
+// Parse is like ParseConfig, using the parser's options and Config.
+func (p *Parser) Parse(args []string) ([]Result, []string, error) {
+	if p.err != nil {
+		return []Result{}, []string{}, p.err
+	}
+	if p.captured == nil {
+		p.Init(nil)
+	}
+
+	results, rest, errs := p.parse(args, false)
+	if len(errs) > 0 {
+		return results, rest, errs[0]
+	}
+	return results, rest, nil
+}
+
+// Reset clears the state left over from the last call to Parse,
+// including its reference to the arguments, but keeps the options.
+// Parse calls it before parsing.
+func (p *Parser) Reset() {
+	p.parser.args = nil
+	p.parser.optind = 0
+	p.parser.subopt = 0
+	p.parser.positionals = nil
+	p.parser.terminated = false
+	p.parser.stopped = false
+}
+
+// Stopped returns the index into the args given to the last call to
+// Parse at which parsing stopped: that of the first non-option
+// argument, the argument after "--", or len(args) if the options ran
+// out. Without Permute, args[Stopped():] is the same as the remaining
+// arguments, so the original command line can be sliced directly.
+func (p *Parser) Stopped() int {
+	return p.parser.optind
+}
+
+// Terminated reports whether the last call to Parse stopped at an
+// explicit "--", or Config.Terminator, which distinguishes "prog --
+// file" from "prog file". The "--" itself is never part of the
+// remaining arguments.
+func (p *Parser) Terminated() bool {
+	return p.parser.terminated
+}
+
+// Split divides the remaining arguments of the last call to Parse at
+// the "--" that stopped it. The positionals are those given before it,
+// which with Permute may have been interleaved with options, and the
+// passthrough arguments are the raw ones after it, for a wrapper to
+// forward verbatim:
+//
+//	prog --flag file -- child --child-flag
+//
+// Without a "--", all remaining arguments are positionals, and
+// passthrough is empty.
+func (p *Parser) Split() (positionals, passthrough []string) {
+	parser := &p.parser
+	if !parser.terminated {
+		return parser.rest(), nil
+	}
+	positionals = append([]string{}, parser.positionals...)
+	return positionals, parser.args[parser.optind:]
+}
+
+// parse parses args with the parser's options. When keepGoing is
+// false, parsing stops at the first error.
+func (p *Parser) parse(args []string, keepGoing bool) ([]Result, []string, []error) {
+	w := p.Output
+	if w == nil {
+		w = os.Stdout
+	}
+
+	warnings := p.Warnings
+	if warnings == nil {
+		warnings = os.Stderr
+	}
+	warned := make(map[string]bool)
+
+	p.Reset()
+	parser := &p.parser
+	parser.args = args
+	_, posixlyCorrect := os.LookupEnv("POSIXLY_CORRECT")
+	parser.permute = p.Permute && !posixlyCorrect
+	parser.numbers = p.NegativeNumbers
+	parser.singleDash = p.SingleDashLong
+	parser.terminator = p.Terminator
+	parser.ignoreUnknown = p.IgnoreUnknown
+	parser.lenient = p.LenientEquals
+	parser.prefix = p.Prefix
+	parser.separator = p.Separator
+	parser.plus = p.PlusNegation
+	parser.lazy = p.LazyOptional
+	options := parser.options
+
+	if p.completes && len(args) > 1 && args[1] == completeFlag {
+		for _, candidate := range complete(options, args[2:]) {
+			fmt.Fprintln(w, candidate)
+		}
+		errs := []error{Error{Option: Option{Long: completeFlag[2:]}, Message: ErrCompletion}}
+		return []Result{}, []string{}, errs
+	}
+
+	// The results stay nil until the first one is found, with
+	// room made then for one per argument; see appendResult.
+	var results []Result
+	var errs []error
+	for {
+		seen := len(parser.positionals)
+		result, err := parser.next()
+		if p.tokens != nil {
+			for _, arg := range parser.positionals[seen:] {
+				*p.tokens = append(*p.tokens, Token{Arg: arg})
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if !keepGoing {
+				return results, parser.rest(), errs
+			}
+			parser.skip(err)
+			continue
+		}
+		if result == nil {
+			rest := parser.rest()
+			if late := parser.lateOption(); p.WarnLateOptions && late != "" {
+				fmt.Fprintf(warnings, "warning: %s follows the non-option argument %s and was not parsed; options must come first, as argument permutation is off\n",
+					late, parser.args[parser.optind])
+			}
+			if p.StdinArgs {
+				var err error
+				rest, err = expandStdin(p.Stdin, rest)
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if p.tokens != nil {
+				for _, arg := range rest[len(parser.positionals):] {
+					*p.tokens = append(*p.tokens, Token{Arg: arg})
+				}
+			}
+
+			given := len(results)
+			var envErrs []error
+			results, envErrs = appendEnv(options, results)
+			errs = append(errs, envErrs...)
+			if p.ConfigFile != "" {
+				var err error
+				results, err = appendFile(p.ConfigFile, options, results, warnings)
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			results = dropExcluded(p.Exclusive, results, given)
+			for i := range results[given:] {
+				result := &results[given+i]
+				if err := result.accept(p.patterns[result.Pattern]); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			errs = append(errs, checkExclusive(p.Exclusive, results)...)
+			errs = append(errs, checkRequires(p.Requires, options, results)...)
+			if p.Args != nil {
+				if err := checkArgs(p.Args, rest); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if missing := MissingRequired(options, results); missing != nil {
+				errs = append(errs, Error{
+					Option:  missing[0],
+					Message: ErrRequired,
+					Missing: missing,
+				})
+			}
+			if len(errs) > 0 && !keepGoing {
+				errs = errs[:1]
+			}
+			return results, rest, errs
+		}
+
+		if p.help && result.Long == "help" {
+			format := p.HelpFormatter
+			if format == nil {
+				style := helpStyle{
+					width:     outputWidth(w),
+					color:     p.HelpColor.enabled(w),
+					flagWidth: p.HelpFlagWidth,
+					descWidth: p.HelpDescWidth,
+					defaults:  p.ShowDefaults,
+				}
+				if result.Optarg == "" {
+					style.args = p.Args
+				}
+				format = func(options []Option) string {
+					var b strings.Builder
+					printHelpStyle(&b, options, style)
+					return b.String()
+				}
+			}
+			shown := p.captured
+			if result.Optarg != "" {
+				if !contains(p.topics, result.Optarg) {
+					errs = append(errs, Error{
+						Option:     result.Option,
+						Message:    ErrTopic,
+						Candidates: p.topics,
+						Optarg:     result.Optarg,
+						Token:      parser.args[parser.index],
+						Index:      parser.index,
+					})
+					return results, parser.rest(), errs
+				}
+				shown = nil
+				for _, option := range p.captured {
+					if option.Group == result.Optarg {
+						shown = append(shown, option)
+					}
+				}
+			}
+			io.WriteString(w, format(shown))
+
+			// Signal the caller, who decides whether to
+			// exit the program.
+			errs = append(errs, Error{Option: result.Option, Message: ErrHelpRequested})
+			return results, parser.rest(), errs
+		}
+
+		if p.version != "" && result.Long == "version" {
+			fmt.Fprintln(w, p.version)
+			errs = append(errs, Error{Option: result.Option, Message: ErrVersionRequested})
+			return results, parser.rest(), errs
+		}
+
+		if err := result.accept(p.patterns[result.Pattern]); err != nil {
+			errs = append(errs, setToken(err, parser.args[parser.index], parser.index))
+			if !keepGoing {
+				return results, parser.rest(), errs
+			}
+			continue
+		}
+
+		if result.Deprecated != "" {
+			if name := result.name(); !warned[name] {
+				fmt.Fprintf(warnings, "warning: %s is deprecated; %s\n", name, result.Deprecated)
+				warned[name] = true
+			}
+		}
+
+		results = appendResult(results, *result, len(args))
+		if p.tokens != nil {
+			option := *result
+			*p.tokens = append(*p.tokens, Token{Option: &option})
+		}
+		if p.StopWhen != nil && p.StopWhen(*result) {
+			parser.stop()
+		}
+	}
+}
+
+// FormatHelp is the default HelpFormatter. Each option gets its own
+// paragraph, with the flag descriptor on the left, and the lines of
+// its Help text lined up to the right of it. Help text is wrapped to
+// the width of the terminal, as given by the COLUMNS environment
+// variable, or to 80 columns when that isn't set.
+func FormatHelp(options []Option) string {
+	return FormatHelpWidth(options, terminalWidth())
+}
+
+// FormatHelpWidth is like FormatHelp, but wraps the Help text to fit
+// within the given number of columns.
+func FormatHelpWidth(options []Option, width int) string {
+	var b strings.Builder
+	printHelpWidth(&b, options, width)
+	return b.String()
+}
+
+// defaultWidth is the width assumed when the terminal's width can't be
+// determined.
+const defaultWidth = 80
+
+// minHelpWidth is the narrowest column Help text is wrapped to, however
+// little room the terminal leaves for it.
+const minHelpWidth = 20
+
+// terminalWidth returns the width of the terminal according to the
+// COLUMNS environment variable, or defaultWidth if it isn't set. The
+// terminal itself isn't queried, which avoids depending on a terminal
+// library, so only COLUMNS is honored. Shells such as bash set it
+// without exporting it, so it usually has to be exported by the user.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultWidth
+}
+
+// outputWidth returns the width help written to w is wrapped to: that
+// of the terminal when w is one, and defaultWidth otherwise, since
+// redirected output has no width of its own.
+func outputWidth(w io.Writer) int {
+	if !isTerminal(w) {
+		return defaultWidth
+	}
+	return terminalWidth()
+}
+
+// printHelp writes the help summary for the given options to w,
+// wrapped to the width of the terminal.
+func printHelp(w io.Writer, options []Option) {
+	printHelpWidth(w, options, terminalWidth())
+}
+
+// printHelpWidth writes the help summary for the given options to w,
+// wrapped to width columns.
+func printHelpWidth(w io.Writer, options []Option, width int) {
+	printHelpStyle(w, options, helpStyle{width: width})
+}
+
+// helpStyle controls the layout of the default help summary.
+type helpStyle struct {
+	// width is the width of the terminal, which Help text is
+	// wrapped to fit within.
+	width int
+
+	// color shows the option names in bold.
+	color bool
+
+	// flagWidth and descWidth are Config.HelpFlagWidth and
+	// Config.HelpDescWidth.
+	flagWidth int
+	descWidth int
+
+	// defaults appends each option's Default to its description.
+	defaults bool
+
+	// args are the positional arguments listed after the options,
+	// from Config.Args.
+	args []ArgSpec
+}
+
+// defaultDescWidth is the width descriptions are padded to by default.
+const defaultDescWidth = 50
+
+// printHelpStyle is like printHelpWidth, but laid out according to
+// style.
+func printHelpStyle(w io.Writer, options []Option, style helpStyle) {
+	if style.flagWidth <= 0 {
+		for _, option := range options {
+			style.flagWidth = max(style.flagWidth, utf8.RuneCountInString(computeFlagDesc(option)))
+		}
+		for _, spec := range style.args {
+			if spec.Help != "" {
+				style.flagWidth = max(style.flagWidth, utf8.RuneCountInString(spec.Name))
+			}
+		}
+	}
+
+	// Before displaying help info, add a newline for visual
+	// appeal.
+	fmt.Fprintln(w)
+
+	for _, option := range options {
+		io.WriteString(w, formatOption(option, style))
+
+		// Print a blank line, to put space between this and
+		// the next printout.
+		fmt.Fprintln(w)
+	}
+
+	// The positional arguments follow, described by their Help,
+	// if they have any.
+	for _, spec := range style.args {
+		if spec.Help != "" {
+			io.WriteString(w, formatEntry(spec.Name, spec.Help, "", style))
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// FormatOption returns the entry for a single option in the help
+// summary: the flag descriptor, followed by the lines of the option's
+// Help text, wrapped to the width of the terminal as with FormatHelp.
+// Every line ends in a newline. This allows custom help layouts to
+// reuse the standard formatting of each option. Taken alone, the
+// option's descriptor sets the width of the left column, so the
+// description starts two columns past it.
+func FormatOption(option Option) string {
+	return formatOption(option, helpStyle{width: terminalWidth()})
+}
+
+// formatOption is FormatOption, laid out according to style.
+func formatOption(option Option, style helpStyle) string {
+	help := option.helpText()
+	if style.defaults && option.Default != "" {
+		help += " (default: " + option.Default + ")"
+	}
+
+	return formatEntry(computeFlagDesc(option), help, option.Example, style)
+}
+
+// formatEntry lays out an entry of the help summary according to
+// style: flagDesc in the left column, and the help text, followed by
+// the example if any, in the right one.
+func formatEntry(flagDesc, help, example string, style helpStyle) string {
+	var b strings.Builder
+
+	descWidth := style.descWidth
+	if descWidth <= 0 {
+		descWidth = defaultDescWidth
+	}
+
+	// The descriptor is padded to the width of the left column,
+	// and the description starts two columns past it. Whatever is
+	// left of the line is room for the description.
+	flagWidth := style.flagWidth
+	if flagWidth <= 0 {
+		flagWidth = utf8.RuneCountInString(flagDesc)
+	}
+	intro, sep := fmt.Sprintf("%-*s", flagWidth, flagDesc), "  "
+	overflow := utf8.RuneCountInString(flagDesc) > flagWidth
+	room := style.width - flagWidth - len(sep)
+	if room < minHelpWidth {
+		room = minHelpWidth
+	}
+
+	// Construct the padding needed for pretty-printing.
+	leftPadding := strings.Repeat(" ", flagWidth)
+
+	if style.color {
+		intro = bold(intro)
+	}
+	if overflow {
+		// The description starts on the next line, under
+		// those of the other options.
+		fmt.Fprintln(&b, intro)
+		intro = leftPadding
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(help))
+	first := true
+	for scanner.Scan() {
+		text := strings.TrimLeft(scanner.Text(), " \t")
+		for _, line := range wrap(text, room) {
+			lineIntro := leftPadding
+			if first {
+				lineIntro, first = intro, false
+			}
+			fmt.Fprintf(&b, "%s%s%-*s\n", lineIntro, sep, descWidth, line)
+		}
+	}
+	if first {
+		fmt.Fprintf(&b, "%s%s%-*s\n", intro, sep, descWidth, "")
+	}
+	if example != "" {
+		fmt.Fprintf(&b, "%s%s%-*s\n", leftPadding, sep, descWidth, "Example: "+example)
+	}
+	return b.String()
+}
+
+// bold styles text in bold, leaving out any trailing spaces, which are
+// only there for alignment. Since the escape sequences take up no room
+// on the screen, alignment is computed from the unstyled text.
+func bold(text string) string {
+	trimmed := strings.TrimRight(text, " ")
+	return "\x1b[1m" + trimmed + "\x1b[0m" + text[len(trimmed):]
+}
+
+// wrap breaks text into lines of at most width characters, breaking
+// only between words. A word longer than width gets a line to itself.
+func wrap(text string, width int) []string {
+	if utf8.RuneCountInString(text) <= width {
+		return []string{text}
+	}
+
+	words := strings.Fields(text)
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line += " " + word
+	}
+	return append(lines, line)
+}
+
+// Optarg returns the argument of the last result for option, or the
+// option's Default when it doesn't appear among the results at all.
+func Optarg(results []Result, option Option) string {
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].Long == option.Long && results[i].Short == option.Short {
+			return results[i].Optarg
+		}
+	}
+	return option.Default
+}
+
+// Count returns how many times an option appears among the results,
+// which is handy for options like -v that may be repeated, as in
+// "-vvv" or "-v --verbose". The option is identified by either its
+// long or short form; pass the zero value for a form to ignore it.
+func Count(results []Result, long string, short rune) int {
+	n := 0
+	for _, result := range results {
+		if result.is(long, short) {
+			n++
+		}
+	}
+	return n
+}
+
+// Values returns the arguments of every occurrence of an option among
+// the results, in the order given, which suits options like -I that
+// accumulate, as in "-I /a -I /b". The option is identified as in
+// Count. Values returns nil when the option doesn't appear.
+func Values(results []Result, long string, short rune) []string {
+	var values []string
+	for _, result := range results {
+		if result.is(long, short) {
+			values = append(values, result.Optarg)
+		}
+	}
+	return values
+}
+
+// KeyValues collects the arguments of every occurrence of an option
+// among the results into a map, each being split into a key and value
+// at its first "=", as in "-D name=value". The value may itself
+// contain "=", and may be empty, but the key may not. Where a key is
+// repeated, the last occurrence wins. The option is identified as in
+// Count. An argument without "=" fails with ErrKeyValue.
+func KeyValues(results []Result, long string, short rune) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, result := range results {
+		if !result.is(long, short) {
+			continue
+		}
+		key, value, ok := strings.Cut(result.Optarg, "=")
+		if !ok || key == "" {
+			return values, Error{Option: result.Option, Message: ErrKeyValue, Optarg: result.Optarg}
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Last returns the argument of the last occurrence of an option among
+// the results, for options where the last one given wins. The option
+// is identified as in Count; ok is false when it doesn't appear.
+func Last(results []Result, long string, short rune) (optarg string, ok bool) {
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].is(long, short) {
+			return results[i].Optarg, true
+		}
+	}
+	return "", false
+}
+
+// Has reports whether an option appears among the results, as in "was
+// --verbose given?". The option is identified as in Count.
+func Has(results []Result, long string, short rune) bool {
+	_, ok := Get(results, long, short)
+	return ok
+}
+
+// Get returns the last occurrence of an option among the results,
+// along with all of its details, such as whether it was Negated. The
+// option is identified as in Count; ok is false when it doesn't
+// appear.
+func Get(results []Result, long string, short rune) (result Result, ok bool) {
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].is(long, short) {
+			return results[i], true
+		}
+	}
+	return Result{}, false
+}
+
+// ResultMap indexes the results by the long name of their option, or
+// for short-only options, by the short form as a string, as in "v", so
+// that m["verbose"] tells whether --verbose was given. Where an option
+// is repeated, the last occurrence wins, as with Get.
+func ResultMap(results []Result) map[string]Result {
+	m := make(map[string]Result, len(results))
+	for _, result := range results {
+		key := result.Long
+		if key == "" {
+			key = string(result.Short)
+		}
+		m[key] = result
+	}
+	return m
+}
+
+// accept normalizes the result's argument, then validates it against
+// the constraints of its option, and passes it to the option's Value
+// and Handler, if any. With NArgs, this is done for each of its
+// arguments in turn. The pattern is the option's Pattern, compiled, or
+// nil if it has none. An explicitly empty argument, as in "--mode=",
+// is validated like any other, while a KindOptional option given bare
+// is only validated against its Default, if any, and a KindNone one
+// not at all.
+func (r *Result) accept(pattern *regexp.Regexp) error {
+	r.normalize()
+	optargs := r.Optargs
+	if optargs == nil {
+		optargs = []string{r.Optarg}
+	}
+	for _, optarg := range optargs {
+		if err := r.acceptOne(pattern, optarg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalize replaces the result's non-empty arguments with their
+// normalized forms, if its option has Normalize.
+func (r *Result) normalize() {
+	if r.Normalize == nil || r.Kind == KindNone {
+		return
+	}
+	if r.Optargs == nil {
+		if r.Optarg != "" {
+			r.Optarg = r.Normalize(r.Optarg)
+		}
+		return
+	}
+	optargs := make([]string, len(r.Optargs))
+	for i, optarg := range r.Optargs {
+		if optarg != "" {
+			optarg = r.Normalize(optarg)
+		}
+		optargs[i] = optarg
+	}
+	r.Optargs = optargs
+	r.Optarg = optargs[0]
+}
+
+// acceptOne is accept for a single argument.
+func (r Result) acceptOne(pattern *regexp.Regexp, optarg string) error {
+	if r.Kind != KindNone && (r.HasArg || optarg != "") {
+		if r.Choices != nil && !contains(r.Choices, optarg) {
+			return Error{
+				Option:     r.Option,
+				Message:    ErrChoice,
+				Candidates: r.Choices,
+				Optarg:     optarg,
+			}
+		}
+		if pattern != nil && !pattern.MatchString(optarg) {
+			return Error{Option: r.Option, Message: ErrPattern, Optarg: optarg}
+		}
+		if r.Range != nil {
+			n, err := strconv.Atoi(optarg)
+			if err != nil {
+				return Error{Option: r.Option, Message: ErrInteger, Optarg: optarg}
+			}
+			if n < r.Range.Min || n > r.Range.Max {
+				return Error{Option: r.Option, Message: ErrRange, Optarg: optarg}
+			}
+		}
+		if r.MustExist {
+			info, err := os.Stat(optarg)
+			if err != nil {
+				return Error{Option: r.Option, Message: ErrNoPath, Err: err}
+			}
+			if (r.PathType == PathFile && !info.Mode().IsRegular()) ||
+				(r.PathType == PathDir && !info.IsDir()) {
+				return Error{Option: r.Option, Message: ErrPathType, Optarg: optarg}
+			}
+		}
+	}
+
+	if r.Value != nil {
+		if err := r.Value.Set(optarg); err != nil {
+			return Error{Option: r.Option, Message: ErrValue, Err: err}
+		}
+	}
+	if r.Handler != nil {
+		if err := r.Handler(optarg); err != nil {
+			return Error{Option: r.Option, Message: ErrHandler, Err: err}
+		}
+	}
+	return nil
 }
 
-// Error represents all possible parsing errors. It embeds the option
-// that has been misused, and Message is one of the three error strings.
-// Implements error.
-type Error struct {
-	Option
-	Message string
+// is reports whether the result belongs to the option with the given
+// long or short form, ignoring forms given as the zero value.
+func (r Result) is(long string, short rune) bool {
+	return (long != "" && r.Long == long) || (short != 0 && r.Short == short)
 }
 
-// computeFlagDesc computes the beginning of a flag's cli help text based on
-// which formats are defined for that flag.
-func computeFlagDesc(long string, short rune) string {
-	if long != "" && short != 0 {
-		return fmt.Sprintf("--%s (-%c)", long, short)
-	} else if long != "" {
-		return fmt.Sprintf("--%s     ", long)
-	} else {
-		return fmt.Sprintf("-%c     ", short)
+// appendResult appends result to results, making room for as many
+// results as there are arguments with the first, since arguments
+// usually hold one option each.
+func appendResult(results []Result, result Result, args int) []Result {
+	if results == nil {
+		results = make([]Result, 0, max(1, args))
 	}
+	return append(results, result)
 }
 
-func (e Error) Error() string {
-	if e.Long != "" && e.Short != 0 {
-		return fmt.Sprintf("%s: --%s (-%c)", e.Message, e.Long, e.Short)
-	} else if e.Long != "" {
-		return fmt.Sprintf("%s: --%s", e.Message, e.Long)
-	} else {
-		return fmt.Sprintf("%s: -%c", e.Message, e.Short)
+// appendEnv appends a result for each option that has an Env variable
+// set in the environment, but was not given on the command line. The
+// variable of a KindNone option holds a boolean, as with fileResult.
+func appendEnv(options []Option, results []Result) ([]Result, []error) {
+	var errs []error
+	given := len(results)
+	for _, option := range options {
+		if option.Env == "" || Count(results[:given], option.Long, option.Short) > 0 {
+			continue
+		}
+		value, ok := os.LookupEnv(option.Env)
+		if !ok {
+			continue
+		}
+		if option.Kind != KindNone {
+			results = append(results, Result{Option: option, Optarg: value, HasArg: true})
+			continue
+		}
+
+		b := false
+		if value != "" {
+			var err error
+			b, err = strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, Error{Option: option, Message: ErrValue, Err: err})
+				continue
+			}
+		}
+		if b || option.Negatable {
+			results = append(results, Result{Option: option, Negated: !b})
+		}
 	}
+	return results, errs
 }
 
-// Result is an individual successfully-parsed option. It embeds the
-// original Option plus any argument. For options with optional
-// arguments (KindOptional), it is not possible determine the difference
-// between an empty supplied argument or no argument supplied.
-type Result struct {
-	Option
-	Optarg string
-}
+// expandStdin replaces a final "-" among the remaining arguments with
+// the lines read from r, or standard input if r is nil, skipping empty
+// ones. The arguments are copied rather than modified in place, since
+// they may share the caller's array.
+func expandStdin(r io.Reader, rest []string) ([]string, error) {
+	if len(rest) == 0 || rest[len(rest)-1] != "-" {
+		return rest, nil
+	}
+	if r == nil {
+		r = os.Stdin
+	}
 
-// Used to capture user-defined options, to extract help info later.
-var capturedOptions = make([]Option, 0)
+	expanded := append([]string{}, rest[:len(rest)-1]...)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			expanded = append(expanded, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return rest, fmt.Errorf("reading arguments from standard input: %w", err)
+	}
+	return expanded, nil
+}
 
-// Parse results a slice of the parsed results, the remaining arguments,
-// and the first parser error. The results slice always contains results
-// up until the first error.
-//
-// The first argument, args[0], is skipped, and arguments are not
-// permuted. Parsing stops at the first non-option argument, or "--".
-// The latter is not included in the remaining, unparsed arguments.
-//
-// goptparse: If --help or -h is given on the command line, a help
-// summary of all commands is printed, and the calling program is
-// instructed to exit. Redefining either --help or -h is illegal, to
-// avoid confusing scenarios.
-func Parse(options []Option, args []string) ([]Result, []string, error) {
-	for _, option := range options {
-		if option.Long == "help" || option.Short == 'h' {
-			return []Result{}, []string{}, Error{Option{"help", 'h', 0, ""}, ErrHelpRedefined}
+// checkExclusive returns an ErrConflict for each exclusive group with
+// more than one member among the results, naming the first two
+// members in the order they appeared.
+func checkExclusive(groups [][]string, results []Result) []error {
+	var errs []error
+	for _, group := range groups {
+		var first *Result
+		for i, result := range results {
+			if !contains(group, result.Long) {
+				continue
+			}
+			if first == nil {
+				first = &results[i]
+			} else if result.Long != first.Long {
+				errs = append(errs, Error{
+					Option:  first.Option,
+					Message: ErrConflict,
+					Other:   result.Option,
+				})
+				break
+			}
 		}
+	}
+	return errs
+}
 
-		// Ensure that the Help field isn't the empty
-		// string. This is mainly to ensure that the user
-		// doesn't forget to add the field in the first place.
-		if option.Help == "" {
-			return []Result{}, []string{}, Error{option, ErrHelpMissing}
+// dropExcluded removes the results past given, which were taken from
+// the environment or a config file, that belong to an exclusive group
+// with another member among the first given results, which came from
+// the command line. Such fallbacks would otherwise conflict with
+// options the user has no other way to override.
+func dropExcluded(groups [][]string, results []Result, given int) []Result {
+	kept := results[:given]
+	for _, result := range results[given:] {
+		if !excluded(groups, results[:given], result.Long) {
+			kept = append(kept, result)
 		}
+	}
+	return kept
+}
 
-		// Capture the given option, for use in the help info
-		// display.
-		capturedOptions = append(capturedOptions, option)
+// excluded reports whether an exclusive group holding long has another
+// member among the results.
+func excluded(groups [][]string, results []Result, long string) bool {
+	for _, group := range groups {
+		if !contains(group, long) {
+			continue
+		}
+		for _, result := range results {
+			if result.Long != long && contains(group, result.Long) {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	// Here is where we add the "help" option.
-	//
-	// It needs to be added to both the original options slice (so
-	// that it's usable!), and to the 'capturedOptions' slice (so
-	// that its own help documentation shows up among the output
-	// of --help itself.)
-	helpOption := Option{"help", 'h', KindNone, "Print this help message"}
-	options = append(options, helpOption)
-	capturedOptions = append(capturedOptions, helpOption)
-
-	parser := parser{options: options, args: args}
-	var results []Result
-	for {
-		result, err := parser.next()
-		if err != nil || result == nil {
-			return results, parser.rest(), err
-		}
-
-		if result.Long == "help" {
-			// Before displaying help info, add a newline
-			// for visual appeal.
-			fmt.Println()
-
-			// Display help info.
-			for _, option := range capturedOptions {
-				// Capture the string representing the
-				// flag introduction, so that we can
-				// use its length to later ensure that
-				// all subsequent lines of text in the
-				// help description respect the
-				// implied right-justification.
-				flagDesc := computeFlagDesc(option.Long, option.Short)
-
-				scanner := bufio.NewScanner(strings.NewReader(option.Help))
-
-				// Scan the first line.
-				scanner.Scan()
-				fmt.Printf("%s\t\t%-50s\n", flagDesc, scanner.Text())
-
-				// Construct the padding needed for
-				// pretty-printing.
-				leftPadding := strings.Repeat(" ", len(flagDesc))
-
-				// Scan and print the remaining lines.
-				for scanner.Scan() {
-					text := strings.TrimLeft(scanner.Text(), " \t")
-					fmt.Printf("%s\t\t%-50s\n", leftPadding, text)
-				}
+// checkRequires returns an ErrDependency for each option among the
+// results that is missing one of its dependencies, reporting each
+// pair only once.
+func checkRequires(requires map[string][]string, options []Option, results []Result) []error {
+	var errs []error
+	reported := make(map[[2]string]bool)
+	for _, result := range results {
+		for _, dependency := range requires[result.Long] {
+			pair := [2]string{result.Long, dependency}
+			if reported[pair] || Count(results, dependency, 0) > 0 {
+				continue
+			}
+			reported[pair] = true
 
-				// Print a blank line, to put space
-				// between this and the next printout.
-				fmt.Println()
+			other := Option{Long: dependency}
+			for _, option := range options {
+				if option.Long == dependency {
+					other = option
+					break
+				}
 			}
+			errs = append(errs, Error{
+				Option:  result.Option,
+				Message: ErrDependency,
+				Other:   other,
+			})
+		}
+	}
+	return errs
+}
 
-			// Exit the program.
-			os.Exit(0)
+// MissingRequired returns the options marked as Required that don't
+// appear among the results, in the order they were defined.
+func MissingRequired(options []Option, results []Result) []Option {
+	var missing []Option
+	for _, option := range options {
+		if option.Required && Count(results, option.Long, option.Short) == 0 {
+			missing = append(missing, option)
 		}
+	}
+	return missing
+}
 
-		results = append(results, *result)
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
 	}
+	return false
 }
 
 // Parser represents the option parsing state between calls to next().
@@ -195,14 +2129,122 @@ type parser struct {
 	args    []string
 	optind  int
 	subopt  int
+
+	// permute makes next() skip over non-option arguments,
+	// collecting them into positionals, instead of stopping.
+	permute     bool
+	positionals []string
+
+	// numbers makes negative numbers count as non-option
+	// arguments; see isNumber.
+	numbers bool
+
+	// singleDash makes next() try arguments with a single dash as
+	// long options when they aren't valid short option clusters.
+	singleDash bool
+
+	// terminator is an argument that stops parsing like "--", or
+	// empty if there is none.
+	terminator string
+
+	// ignoreUnknown makes next() set unrecognized options aside
+	// with the positionals; see passUnknown.
+	ignoreUnknown bool
+
+	// stopped makes next() report that the options ran out; see
+	// stop.
+	stopped bool
+
+	// lenient makes long() accept an "=" given apart from the
+	// option; see spacedEquals.
+	lenient bool
+
+	// prefix, if not empty, introduces options instead of dashes,
+	// and separator attaches arguments to them instead of "=".
+	prefix    string
+	separator string
+
+	// plus lets clusters of KindNone short options start with a
+	// "+", negating them.
+	plus bool
+
+	// lazy makes a KindOptional short option leave the rest of
+	// its cluster to be parsed as options when it can be.
+	lazy bool
+
+	// terminated records that parsing stopped at a "--", or at
+	// the terminator.
+	terminated bool
+
+	// index is the position of the argument next() last looked
+	// at, which holds the option of the result it returns.
+	index int
+
+	// longs and shorts index the options by name, so that each
+	// argument can be looked up without scanning all of them.
+	longs  map[string]*Option
+	shorts map[rune]*Option
+}
+
+// newParser returns a parser for args, with its options indexed by
+// name. Where names are duplicated, the first option wins, just as
+// with findLong and findShort.
+func newParser(options []Option, args []string) *parser {
+	p := &parser{
+		options: options,
+		args:    args,
+		longs:   make(map[string]*Option, len(options)),
+		shorts:  make(map[rune]*Option, len(options)),
+	}
+	for i, option := range options {
+		for _, name := range option.longNames() {
+			if _, ok := p.longs[name]; !ok {
+				p.longs[name] = &options[i]
+			}
+		}
+		if _, ok := p.shorts[option.Short]; option.Short != 0 && !ok {
+			p.shorts[option.Short] = &options[i]
+		}
+	}
+	return p
+}
+
+// findLong is like the function of the same name, but tries the index
+// first, so that only abbreviations need a full scan. It also resolves
+// the "no-" form of a Negatable option, reporting it as negated. Both
+// kinds of exact match win over abbreviations.
+func (p *parser) findLong(long string) (*Option, bool, []string) {
+	if option, ok := p.longs[long]; ok {
+		return option, false, nil
+	}
+	if name := strings.TrimPrefix(long, "no-"); name != long {
+		if option, ok := p.longs[name]; ok && option.Negatable {
+			return option, true, nil
+		}
+	}
+	option, candidates := findLong(p.options, long)
+	return option, false, candidates
+}
+
+// findShort is like the function of the same name, but uses the index
+// when there is one.
+func (p *parser) findShort(short rune) *Option {
+	if p.shorts == nil {
+		return findShort(p.options, short)
+	}
+	return p.shorts[short]
 }
 
 func (p *parser) short() (*Result, error) {
 	runes := []rune(p.args[p.optind])
 	c := runes[p.subopt]
-	option := findShort(p.options, c)
+	option := p.findShort(c)
 	if option == nil {
-		return nil, Error{Option{"", c, 0, ""}, ErrInvalid}
+		return nil, Error{Option: Option{Short: c}, Message: ErrInvalid}
+	}
+	negated := runes[0] == '+'
+	if negated && option.Kind != KindNone {
+		return nil, Error{Option: *option, Message: ErrNotNegatable}
 	}
 	switch option.Kind {
 
@@ -212,69 +2254,162 @@ func (p *parser) short() (*Result, error) {
 			p.subopt = 0
 			p.optind++
 		}
-		return &Result{*option, ""}, nil
+		return &Result{Option: *option, Negated: negated}, nil
 
 	case KindRequired:
-		optarg := string(runes[p.subopt+1:])
+		// The rest of the cluster, if any, is the argument,
+		// so "-xfvalue" gives f the argument "value", wherever
+		// f sits in the cluster. At the end of the cluster,
+		// as in "-xf", the next argument is taken instead.
+		optarg, attached := p.attached(runes)
 		p.subopt = 0
 		p.optind++
-		if optarg == "" {
-			if p.optind == len(p.args) {
-				return nil, Error{*option, ErrMissing}
-			}
-			optarg = p.args[p.optind]
-			p.optind++
-		}
-		return &Result{*option, optarg}, nil
+		return p.required(option, optarg, attached)
 
 	case KindOptional:
-		optarg := string(runes[p.subopt+1:])
+		// As with getopt, the rest of the cluster, if any, is
+		// the argument, so "-vOx" gives O the argument "x",
+		// even if x is itself an option. At the end of the
+		// cluster, as in "-vO", there is no argument, and the
+		// next one is never taken. When lazy, a rest of the
+		// cluster made of options is left to them instead.
+		if p.lazy && p.subopt+1 < len(runes) && p.isCluster("-"+string(runes[p.subopt+1:])) {
+			p.subopt++
+			return &Result{Option: *option, Optarg: option.Default}, nil
+		}
+		optarg, attached := p.attached(runes)
 		p.subopt = 0
 		p.optind++
-		return &Result{*option, optarg}, nil
+		if optarg == "" {
+			optarg = option.Default
+		}
+		return &Result{Option: *option, Optarg: optarg, HasArg: attached}, nil
 
 	}
 	panic("invalid Kind")
 }
 
-func (p *parser) long() (*Result, error) {
-	long := p.args[p.optind][2:]
+// attached returns the rest of the short option cluster following the
+// option at subopt, which is that option's attached argument, and
+// whether there was any. As with "--option=arg", a "=" right after the
+// option is dropped, so that "-o=file" means the same as "-ofile".
+// Only one is dropped, so "-o==x" gives "=x", and "-o=" gives an
+// explicitly empty argument.
+func (p *parser) attached(runes []rune) (string, bool) {
+	rest := runes[p.subopt+1:]
+	if len(rest) > 0 && rest[0] == '=' {
+		return string(rest[1:]), true
+	}
+	return string(rest), len(rest) > 0
+}
+
+// required finishes parsing a KindRequired option, given its attached
+// argument, if any, by taking its arguments from those that follow.
+func (p *parser) required(option *Option, optarg string, attached bool) (*Result, error) {
+	if attached && (option.NArgs > 1 || option.NoAttached) {
+		return nil, Error{Option: *option, Message: ErrAttached}
+	}
+	if option.NArgs > 1 {
+		if len(p.args)-p.optind < option.NArgs {
+			return nil, Error{Option: *option, Message: ErrMissing}
+		}
+		optargs := append([]string{}, p.args[p.optind:p.optind+option.NArgs]...)
+		p.optind += option.NArgs
+		return &Result{Option: *option, Optarg: optargs[0], HasArg: true, Optargs: optargs}, nil
+	}
+
+	if !attached {
+		if p.optind == len(p.args) {
+			return nil, Error{Option: *option, Message: ErrMissing}
+		}
+		optarg = p.args[p.optind]
+		p.optind++
+	}
+	return &Result{Option: *option, Optarg: optarg, HasArg: true}, nil
+}
+
+// spacedEquals looks for an argument attached by an "=" that stands
+// apart from its long option, as in "--output = file" or "--output
+// =file", and consumes it if found.
+func (p *parser) spacedEquals() (string, bool) {
+	if p.optind == len(p.args) {
+		return "", false
+	}
+	next := p.args[p.optind]
+	if next == "=" && p.optind+1 < len(p.args) {
+		p.optind += 2
+		return p.args[p.optind-1], true
+	}
+	if len(next) > 1 && next[0] == '=' {
+		p.optind++
+		return next[1:], true
+	}
+	return "", false
+}
+
+// long parses a long option, which is preceded by the given number of
+// dashes, or by the prefix. With the prefix, a name of one character
+// may also be a short option.
+func (p *parser) long(dashes int) (*Result, error) {
+	long := p.args[p.optind][dashes:]
 
-	eq := strings.IndexByte(long, '=')
-	var optarg string
-	var attached bool
-	if eq != -1 {
-		optarg = long[eq+1:]
-		long = long[:eq]
-		attached = true
+	separator := p.separator
+	if separator == "" {
+		separator = "="
 	}
+	long, optarg, attached := strings.Cut(long, separator)
 
-	option := findLong(p.options, long)
+	var option *Option
+	var negated bool
+	if p.prefix != "" && utf8.RuneCountInString(long) == 1 {
+		option = p.findShort([]rune(long)[0])
+	}
 	if option == nil {
-		return nil, Error{Option{long, 0, 0, ""}, ErrInvalid}
+		var candidates []string
+		option, negated, candidates = p.findLong(long)
+		if candidates != nil {
+			return nil, Error{
+				Option:     Option{Long: long},
+				Message:    ErrAmbiguous,
+				Candidates: candidates,
+			}
+		}
+		if option == nil {
+			return nil, Error{
+				Option:     Option{Long: long},
+				Message:    ErrInvalid,
+				Suggestion: suggest(p.options, long),
+			}
+		}
 	}
 	p.optind++
 
+	if negated {
+		if attached {
+			return nil, Error{Option: *option, Message: ErrTooMany}
+		}
+		return &Result{Option: *option, Negated: true}, nil
+	}
+	if !attached && p.lenient && option.Kind != KindNone {
+		optarg, attached = p.spacedEquals()
+	}
+
 	switch option.Kind {
 
 	case KindNone:
 		if attached {
-			return nil, Error{*option, ErrTooMany}
+			return nil, Error{Option: *option, Message: ErrTooMany}
 		}
-		return &Result{*option, ""}, nil
+		return &Result{Option: *option}, nil
 
 	case KindRequired:
-		if p.optind == len(p.args) {
-			return nil, Error{*option, ErrMissing}
-		}
-		if !attached {
-			optarg = p.args[p.optind]
-			p.optind++
-		}
-		return &Result{*option, optarg}, nil
+		return p.required(option, optarg, attached)
 
 	case KindOptional:
-		return &Result{*option, optarg}, nil
+		if optarg == "" {
+			optarg = option.Default
+		}
+		return &Result{Option: *option, Optarg: optarg, HasArg: attached}, nil
 
 	}
 	panic("invalid Kind")
@@ -285,49 +2420,332 @@ func (p *parser) long() (*Result, error) {
 //
 // If there is an error, the associated argument is not consumed.
 func (p *parser) next() (*Result, error) {
-	if p.optind == 0 {
-		p.optind = 1 // initialize
+	if p.optind == 0 && len(p.args) > 0 {
+		p.optind = 1 // initialize, skipping the program name
 	}
 
-	if p.optind == len(p.args) {
-		return nil, nil
+	for {
+		if p.optind == len(p.args) || p.stopped {
+			return nil, nil
+		}
+		arg, index := p.args[p.optind], p.optind
+		p.index = index
+
+		if p.subopt > 0 {
+			// continue parsing short options
+			result, err := p.short()
+			if p.passUnknown(err) {
+				continue
+			}
+			return result, setToken(err, arg, index)
+		}
+
+		if arg == "--" || (p.terminator != "" && arg == p.terminator) {
+			p.optind++
+			p.terminated = true
+			return nil, nil
+		}
+
+		if !p.isOption(arg) {
+			if !p.permute {
+				return nil, nil
+			}
+
+			// set the argument aside and keep looking
+			p.positionals = append(p.positionals, arg)
+			p.optind++
+			continue
+		}
+
+		if p.prefix != "" {
+			result, err := p.long(len(p.prefix))
+			if p.passUnknown(err) {
+				continue
+			}
+			return result, setToken(err, arg, index)
+		}
+		if arg[0] == '+' {
+			p.subopt = 1
+			result, err := p.short()
+			if p.passUnknown(err) {
+				continue
+			}
+			return result, setToken(err, arg, index)
+		}
+		if arg[:2] == "--" {
+			result, err := p.long(2)
+			if p.passUnknown(err) {
+				continue
+			}
+			return result, setToken(err, arg, index)
+		}
+		if p.singleDash && !p.isCluster(arg) && p.isLong(arg[1:]) {
+			result, err := p.long(1)
+			return result, setToken(err, arg, index)
+		}
+		p.subopt = 1
+		result, err := p.short()
+		if p.passUnknown(err) {
+			continue
+		}
+		return result, setToken(err, arg, index)
+	}
+}
+
+// isOption reports whether arg is an option, rather than a non-option
+// argument. A lone "-", which conventionally means standard input, is
+// a non-option argument like any other.
+func (p *parser) isOption(arg string) bool {
+	if p.prefix != "" {
+		return len(arg) > len(p.prefix) && strings.HasPrefix(arg, p.prefix)
+	}
+	if p.plus && len(arg) >= 2 && arg[0] == '+' {
+		return true
+	}
+	return len(arg) >= 2 && arg[0] == '-' && !p.isNumber(arg)
+}
+
+// lateOption returns the first argument left unparsed that looks like
+// an option, up to any "--", or the empty string if there is none or
+// parsing didn't stop at a non-option argument.
+func (p *parser) lateOption() string {
+	if p.permute || p.stopped || p.terminated {
+		return ""
+	}
+	for _, arg := range p.args[p.optind:] {
+		if arg == "--" || (p.terminator != "" && arg == p.terminator) {
+			break
+		}
+		if p.isOption(arg) {
+			return arg
+		}
+	}
+	return ""
+}
+
+// passUnknown sets the argument being parsed aside with the
+// positionals, and reports true, if err is for an unrecognized option
+// and ignoreUnknown is set. Within a cluster of short options, only
+// the rest of the cluster, starting at the unknown one, is set aside.
+func (p *parser) passUnknown(err error) bool {
+	e, ok := err.(Error)
+	if !p.ignoreUnknown || !ok || e.Message != ErrInvalid {
+		return false
 	}
+
+	p.setAside()
+	return true
+}
+
+// setAside moves the argument being parsed to the positionals, or only
+// the rest of it, as a new cluster, when partway through a cluster of
+// short options.
+func (p *parser) setAside() {
 	arg := p.args[p.optind]
+	if p.subopt > 0 {
+		runes := []rune(arg)
+		arg = string(runes[0]) + string(runes[p.subopt:])
+		p.subopt = 0
+	}
+	p.positionals = append(p.positionals, arg)
+	p.optind++
+}
 
+// stop ends parsing early, so that next() reports that the options ran
+// out, leaving the arguments not yet parsed, including the rest of any
+// cluster, as the remaining ones.
+func (p *parser) stop() {
 	if p.subopt > 0 {
-		// continue parsing short options
-		return p.short()
+		p.setAside()
 	}
+	p.stopped = true
+}
 
-	if len(arg) < 2 || arg[0] != '-' {
-		return nil, nil
+// setToken records token, found at index in the arguments, as the
+// argument that caused err, if err is an Error.
+func setToken(err error, token string, index int) error {
+	if e, ok := err.(Error); ok {
+		e.Token = token
+		e.Index = index
+		return e
 	}
+	return err
+}
 
-	if arg == "--" {
-		p.optind++
-		return nil, nil
+// isCluster reports whether arg, which starts with a single dash, is a
+// valid cluster of short options: each of its characters must be a
+// short option, up to the first one taking an argument, which takes
+// the rest.
+func (p *parser) isCluster(arg string) bool {
+	for _, c := range arg[1:] {
+		option := p.findShort(c)
+		if option == nil {
+			return false
+		} else if option.Kind != KindNone {
+			return true
+		}
+	}
+	return true
+}
+
+// isLong reports whether arg, less its dashes and any "=" argument,
+// names a long option, or abbreviates at least one.
+func (p *parser) isLong(arg string) bool {
+	if eq := strings.IndexByte(arg, '='); eq != -1 {
+		arg = arg[:eq]
+	}
+	if arg == "" {
+		return false
+	}
+	option, _, candidates := p.findLong(arg)
+	return option != nil || candidates != nil
+}
+
+// numberPattern matches negative decimal numbers, with an optional
+// fraction and exponent. Unlike strconv.ParseFloat, it leaves out
+// words such as "-inf" and "-nan", and hexadecimal and underscored
+// forms, which are more likely to be clusters of short options.
+var numberPattern = regexp.MustCompile(`^-([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+
+// isNumber reports whether arg should be taken as a negative number
+// rather than as options, which is only done when the parser is set up
+// to allow it. That is the case when arg matches numberPattern, and
+// its first digit isn't itself a short option.
+func (p *parser) isNumber(arg string) bool {
+	if !p.numbers || !numberPattern.MatchString(arg) {
+		return false
+	}
+	first := []rune(arg)[1]
+	return p.findShort(first) == nil
+}
+
+// skip moves past the argument responsible for err, so that parsing
+// can resume after it. Only invalid, ambiguous, and wrongly negated
+// options leave their argument unconsumed; for a short option, just
+// the offending character within its cluster is skipped.
+func (p *parser) skip(err error) {
+	e, ok := err.(Error)
+	if !ok || (e.Message != ErrInvalid && e.Message != ErrAmbiguous && e.Message != ErrNotNegatable) {
+		return
 	}
 
-	if arg[:2] == "--" {
-		return p.long()
+	if p.subopt > 0 {
+		p.subopt++
+		if p.subopt == len([]rune(p.args[p.optind])) {
+			p.subopt = 0
+			p.optind++
+		}
+		return
 	}
-	p.subopt = 1
-	return p.short()
+	p.optind++
 }
 
 // Args slices the argument slice to return the arguments that were not
-// parsed, excluding the "--".
+// parsed, excluding the "--". When permuting, any non-option arguments
+// that were skipped over come first.
 func (p *parser) rest() []string {
-	return p.args[p.optind:]
+	if p.positionals == nil {
+		return p.args[p.optind:]
+	}
+	return append(p.positionals, p.args[p.optind:]...)
 }
 
-func findLong(options []Option, long string) *Option {
+// findLong looks up a long option by name, or by one of its aliases.
+// Like getopt_long(), the name may be abbreviated to any unambiguous
+// prefix, though an exact match always wins. If the prefix is
+// ambiguous, the returned option is nil and the long names of all
+// matching options are returned instead, sorted so that error messages
+// don't depend on the order the options were defined in. Hidden options
+// must be given in full, so that they neither show up among the
+// candidates nor make a prefix of a visible option ambiguous.
+func findLong(options []Option, long string) (*Option, []string) {
+	if long == "" {
+		return nil, nil
+	}
+
+	var match *Option
+	var candidates []string
 	for i, option := range options {
-		if option.Long == long {
-			return &options[i]
+		matched := false
+		for _, name := range option.longNames() {
+			if name == long {
+				return &options[i], nil
+			}
+			matched = matched || strings.HasPrefix(name, long)
+		}
+
+		// An option matching through more than one of its
+		// names still counts once.
+		if matched && !option.Hidden {
+			match = &options[i]
+			candidates = append(candidates, option.Long)
 		}
 	}
-	return nil
+
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		return nil, candidates
+	}
+	return match, nil
+}
+
+// suggest returns the long name of the option closest to the unknown
+// name long, provided it's close enough to be a plausible typo: the
+// edit distance may be at most a third of the name's length, though
+// always at least one. Returns the empty string otherwise. Hidden
+// options are never suggested.
+func suggest(options []Option, long string) string {
+	limit := len([]rune(long)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	best := ""
+	for _, option := range options {
+		if option.Hidden {
+			continue
+		}
+		for _, name := range option.longNames() {
+			if d := distance(long, name); d <= limit {
+				best, limit = name, d-1
+			}
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance between a and b, counted
+// in runes.
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
 }
 
 func findShort(options []Option, short rune) *Option {
@@ -1,21 +1,33 @@
 package v2
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 var options = []Option{
-	{"amend", 'a', KindNone, "amend a foo"},
-	{"brief", 'b', KindNone, "perform a brief scan"},
-	{"color", 'c', KindOptional, "colorize output"},
-	{"delay", 'd', KindRequired, "delay ARG milliseconds"},
-	{"erase", 'e', KindNone, "erase current changes"},
+	{Long: "amend", Short: 'a', Kind: KindNone, Help: "amend a foo"},
+	{Long: "brief", Short: 'b', Kind: KindNone, Help: "perform a brief scan"},
+	{Long: "color", Short: 'c', Kind: KindOptional, Help: "colorize output"},
+	{Long: "delay", Short: 'd', Kind: KindRequired, Help: "delay ARG milliseconds"},
+	{Long: "erase", Short: 'e', Kind: KindNone, Help: "erase current changes"},
 
 	// special cases
-	{"pi", 'π', KindNone, "3.14"},                     // multibyte short option
-	{"long", 0, KindNone, "zero-value"},               // long only
-	{"", 's', KindNone, "quick switch configuration"}, // short only
+	{Long: "pi", Short: 'π', Kind: KindNone, Help: "3.14"},           // multibyte short option
+	{Long: "long", Kind: KindNone, Help: "zero-value"},               // long only
+	{Short: 's', Kind: KindNone, Help: "quick switch configuration"}, // short only
 }
 
 type config struct {
@@ -30,6 +42,11 @@ type config struct {
 func parse(args []string) (conf config, rest []string, err error) {
 	var results []Result
 	results, rest, err = Parse(options, args)
+	conf = configure(results)
+	return
+}
+
+func configure(results []Result) (conf config) {
 	for _, result := range results {
 		switch result.Long {
 		case "amend":
@@ -135,19 +152,19 @@ func TestParse(t *testing.T) {
 			[]string{"", "--delay"},
 			config{false, false, "", 0, 0, 0},
 			[]string{},
-			Error{Option{"delay", 'd', KindRequired, "delay ARG milliseconds"}, ErrMissing},
+			Error{Option: Option{Long: "delay", Short: 'd', Kind: KindRequired, Help: "delay ARG milliseconds"}, Message: ErrMissing, Token: "--delay", Index: 1},
 		},
 		{
 			[]string{"", "--foo", "bar"},
 			config{false, false, "", 0, 0, 0},
 			[]string{"--foo", "bar"},
-			Error{Option{"foo", 0, 0, ""}, ErrInvalid},
+			Error{Option: Option{Long: "foo"}, Message: ErrInvalid, Token: "--foo", Index: 1},
 		},
 		{
 			[]string{"", "-x"},
 			config{false, false, "", 0, 0, 0},
 			[]string{"-x"},
-			Error{Option{"", 'x', 0, ""}, ErrInvalid},
+			Error{Option: Option{Short: 'x'}, Message: ErrInvalid, Token: "-x", Index: 1},
 		},
 		{
 			[]string{"", "-"},
@@ -159,7 +176,7 @@ func TestParse(t *testing.T) {
 			[]string{"", "-\x00"},
 			config{false, false, "", 0, 0, 0},
 			[]string{"-\x00"},
-			Error{Option{"", 0, 0, ""}, ErrInvalid},
+			Error{Option: Option{}, Message: ErrInvalid, Token: "-\x00", Index: 1},
 		},
 	}
 
@@ -175,7 +192,7 @@ func TestParse(t *testing.T) {
 			want := row.err.(Error)
 			if err == nil {
 				t.Errorf("parse(%q), got nil, wanted %#v", row.args[1:], want)
-			} else if got := err.(Error); got != want {
+			} else if got := err.(Error); !reflect.DeepEqual(got, want) {
 				t.Errorf("parse(%q), got %#v, wanted %#v",
 					row.args[1:], got, want)
 			}
@@ -186,8 +203,8 @@ func TestParse(t *testing.T) {
 func TestGoptparse(t *testing.T) {
 	// Check that our application guards against help-flag
 	// redefinition.
-	longHelp := Option{"help", 'η', KindNone, "Display this help message"}
-	shortHelp := Option{"ayuda", 'h', KindNone, "Display this help message"}
+	longHelp := Option{Long: "help", Short: 'η', Kind: KindNone, Help: "Display this help message"}
+	shortHelp := Option{Long: "ayuda", Short: 'h', Kind: KindNone, Help: "Display this help message"}
 
 	_, _, err := Parse([]Option{longHelp}, []string{})
 
@@ -214,3 +231,3469 @@ func TestGoptparse(t *testing.T) {
 		t.Error("Absent Help field should be illegal")
 	}
 }
+
+// Since --help exits the program, the second Parse call is run in a
+// subprocess, and its output is inspected here.
+func TestHelpCapturedOptionsReset(t *testing.T) {
+	if os.Getenv("GOPTPARSE_HELP_SUBPROCESS") == "1" {
+		first := []Option{{Long: "first", Short: 'f', Kind: KindNone, Help: "the first option"}}
+		second := []Option{{Long: "second", Short: 's', Kind: KindNone, Help: "the second option"}}
+
+		Parse(first, []string{""})
+		Parse(second, []string{"", "--help"})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelpCapturedOptionsReset$")
+	cmd.Env = append(os.Environ(), "GOPTPARSE_HELP_SUBPROCESS=1")
+	out, err := cmd.Output()
+
+	if err != nil {
+		t.Fatalf("help subprocess failed: %v", err)
+	}
+
+	help := string(out)
+
+	if !strings.Contains(help, "--second") {
+		t.Errorf("help output is missing --second:\n%s", help)
+	}
+
+	if strings.Contains(help, "--first") {
+		t.Errorf("help output leaked --first from a previous call:\n%s", help)
+	}
+}
+
+func TestHelpDoesNotAlias(t *testing.T) {
+	backing := make([]Option, 3, 4)
+	copy(backing, options[:3])
+	sentinel := Option{Long: "sentinel", Kind: KindNone, Help: "must survive"}
+	backing = append(backing, sentinel)
+	callerOptions := backing[:3]
+
+	if _, _, err := ParseWithOutput(callerOptions, []string{"", "-a"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("ParseWithOutput(), got error %v", err)
+	}
+	if !reflect.DeepEqual(callerOptions, options[:3]) {
+		t.Errorf("ParseWithOutput() changed the options to %v", callerOptions)
+	}
+	if got := backing[:4][3]; !reflect.DeepEqual(got, sentinel) {
+		t.Errorf("ParseWithOutput() overwrote the backing array with %v", got)
+	}
+
+	// The copy also means later changes to the caller's slice
+	// don't reach a Parser.
+	var p Parser
+	p.Output = &bytes.Buffer{}
+	if err := p.Init(callerOptions); err != nil {
+		t.Fatalf("Init(), got error %v", err)
+	}
+	callerOptions[0].Long = "changed"
+	if _, _, err := p.Parse([]string{"", "--amend"}); err != nil {
+		t.Errorf("Parse() after changing the options, got %v", err)
+	}
+}
+
+func TestPrintHelp(t *testing.T) {
+	helpOptions := []Option{
+		{Long: "amend", Short: 'a', Kind: KindNone, Help: "amend a foo"},
+		{Short: 's', Kind: KindNone, Help: "quick switch\n    configuration"},
+	}
+
+	var buf bytes.Buffer
+	printHelp(&buf, helpOptions)
+
+	// The left column is as wide as the longest descriptor.
+	want := "\n" +
+		fmt.Sprintf("--amend (-a)  %-50s\n", "amend a foo") +
+		"\n" +
+		fmt.Sprintf("-s            %-50s\n", "quick switch") +
+		fmt.Sprintf("              %-50s\n", "configuration") +
+		"\n"
+
+	if got := buf.String(); got != want {
+		t.Errorf("printHelp() wrote %q, want %q", got, want)
+	}
+}
+
+func TestHelpConfig(t *testing.T) {
+	host := Option{Long: "host", Short: 'h', Kind: KindRequired, Help: "connect to HOST"}
+
+	// Without auto-help, -h and --help are free for the taking.
+	var buf bytes.Buffer
+	conf := Config{Output: &buf, NoHelp: true}
+	results, _, err := ParseConfig([]Option{host}, []string{"", "-h", "example.com"}, conf)
+	if err != nil || len(results) != 1 || results[0].Optarg != "example.com" {
+		t.Errorf("ParseConfig() with NoHelp, got %v, %v", results, err)
+	}
+	_, _, err = ParseConfig([]Option{host}, []string{"", "--help"}, conf)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseConfig([--help]) with NoHelp, got %v, want %q", err, ErrInvalid)
+	}
+	help := Option{Long: "help", Kind: KindOptional, Help: "show help on TOPIC"}
+	results, _, err = ParseConfig([]Option{help}, []string{"", "--help=colors"}, conf)
+	if err != nil || len(results) != 1 || results[0].Optarg != "colors" {
+		t.Errorf("ParseConfig() with NoHelp and a custom --help, got %v, %v", results, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("ParseConfig() with NoHelp wrote help:\n%s", buf.String())
+	}
+
+	// Relocating the short form frees -h, and -? asks for help.
+	conf = Config{Output: &buf, HelpShort: '?'}
+	results, _, err = ParseConfig([]Option{host}, []string{"", "-h", "example.com"}, conf)
+	if err != nil || len(results) != 1 {
+		t.Errorf("ParseConfig() with HelpShort, got %v, %v", results, err)
+	}
+	_, _, err = ParseConfig([]Option{host}, []string{"", "-?"}, conf)
+	if !errors.Is(err, ErrHelpRequested) {
+		t.Errorf("ParseConfig([-?]) with HelpShort, got %v, want %q", err, ErrHelpRequested)
+	}
+	if !strings.Contains(buf.String(), "--help (-?)") {
+		t.Errorf("help output is missing --help (-?):\n%s", buf.String())
+	}
+	wantErr := Error{Option: Option{Long: "help", Short: '?'}, Message: ErrHelpRedefined}
+	_, _, err = ParseConfig([]Option{{Short: '?', Kind: KindNone, Help: "huh"}}, []string{""}, conf)
+	if !reflect.DeepEqual(err, wantErr) {
+		t.Errorf("ParseConfig() redefining -?, got %v, want %v", err, wantErr)
+	}
+	// An alias can't take over --help either.
+	assist := Option{Long: "assist", Aliases: []string{"help"}, Kind: KindNone, Help: "get assistance"}
+	_, _, err = ParseConfig([]Option{assist}, []string{"", "--help"}, conf)
+	if !reflect.DeepEqual(err, wantErr) {
+		t.Errorf("ParseConfig() aliasing --help, got %v, want %v", err, wantErr)
+	}
+
+	// A negative HelpShort leaves --help alone.
+	conf = Config{Output: &buf, HelpShort: -1}
+	_, _, err = ParseConfig(options, []string{"", "-h"}, conf)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseConfig([-h]) without a short help, got %v, want %q", err, ErrInvalid)
+	}
+	_, _, err = ParseConfig(options, []string{"", "--help"}, conf)
+	if !errors.Is(err, ErrHelpRequested) {
+		t.Errorf("ParseConfig([--help]) without a short help, got %v, want %q", err, ErrHelpRequested)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	var buf bytes.Buffer
+	conf := Config{Output: &buf, Version: "prog 1.2.3", VersionShort: 'V'}
+
+	for _, args := range [][]string{{"", "-a", "--version", "foo"}, {"", "-aV", "foo"}} {
+		buf.Reset()
+		results, rest, err := ParseConfig(options, args, conf)
+		if !errors.Is(err, ErrVersionRequested) {
+			t.Errorf("ParseConfig(%q), got %v, want %q", args[1:], err, ErrVersionRequested)
+		}
+		if len(results) != 1 || results[0].Long != "amend" || !equal(rest, []string{"foo"}) {
+			t.Errorf("ParseConfig(%q), got %v, %v, want only --amend", args[1:], results, rest)
+		}
+		if got := buf.String(); got != "prog 1.2.3\n" {
+			t.Errorf("ParseConfig(%q) wrote %q, want the version", args[1:], got)
+		}
+	}
+
+	// The version option shows up in help.
+	buf.Reset()
+	ParseConfig(options, []string{"", "--help"}, conf)
+	if !strings.Contains(buf.String(), "--version (-V)") {
+		t.Errorf("help output is missing --version (-V):\n%s", buf.String())
+	}
+
+	// Without a Version, --version is an ordinary option.
+	_, _, err := ParseConfig(options, []string{"", "--version"}, Config{Output: &buf})
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseConfig([--version]) without a Version, got %v, want %q", err, ErrInvalid)
+	}
+	version := Option{Long: "version", Kind: KindNone, Help: "print the version"}
+	if _, _, err := ParseConfig([]Option{version}, []string{"", "--version"}, Config{}); err != nil {
+		t.Errorf("ParseConfig() defining --version, got %v", err)
+	}
+
+	// With one, it can't be redefined.
+	alias := Option{Long: "show-version", Aliases: []string{"version"}, Kind: KindNone, Help: "print the version"}
+	for _, option := range []Option{version, {Short: 'V', Kind: KindNone, Help: "be verbose"}, alias} {
+		want := Error{Option: Option{Long: "version", Short: 'V'}, Message: ErrVersionRedefined}
+		if _, _, err := ParseConfig([]Option{option}, []string{""}, conf); !reflect.DeepEqual(err, want) {
+			t.Errorf("ParseConfig() redefining %s, got %v, want %v", option.name(), err, want)
+		}
+	}
+}
+
+func TestHelpColor(t *testing.T) {
+	colorOptions := []Option{
+		{Long: "amend", Short: 'a', Kind: KindNone, Help: "amend a foo"},
+		{Short: 's', Kind: KindNone, Help: "quick switch\n    configuration"},
+	}
+	plain := FormatHelpWidth(append(colorOptions[:2:2], helpOption), 80)
+
+	table := []struct {
+		mode    ColorMode
+		noColor string
+		styled  bool
+	}{
+		{ColorAlways, "", true},
+		{ColorAlways, "1", true},
+		{ColorNever, "", false},
+		// a bytes.Buffer is never a terminal
+		{ColorAuto, "", false},
+	}
+
+	for _, row := range table {
+		t.Setenv("NO_COLOR", row.noColor)
+		t.Setenv("COLUMNS", "80")
+		var buf bytes.Buffer
+		ParseConfig(colorOptions, []string{"", "-h"}, Config{Output: &buf, HelpColor: row.mode})
+		got := buf.String()
+
+		if styled := strings.Contains(got, "\x1b["); styled != row.styled {
+			t.Errorf("help with HelpColor %d and NO_COLOR=%q, got styled %v, want %v:\n%q",
+				row.mode, row.noColor, styled, row.styled, got)
+		}
+		if row.styled {
+			if !strings.Contains(got, "\x1b[1m--amend (-a)\x1b[0m  ") ||
+				!strings.Contains(got, "\x1b[1m-s\x1b[0m            ") {
+				t.Errorf("help with HelpColor %d, missing styled names:\n%q", row.mode, got)
+			}
+		}
+
+		// Styling leaves alignment alone.
+		unstyled := strings.NewReplacer("\x1b[1m", "", "\x1b[0m", "").Replace(got)
+		if unstyled != plain {
+			t.Errorf("help with HelpColor %d, got %q once unstyled, want %q", row.mode, unstyled, plain)
+		}
+	}
+}
+
+func TestParseOSArgs(t *testing.T) {
+	saved := os.Args
+	defer func() { os.Args = saved }()
+
+	os.Args = []string{"prog", "-a", "--delay", "10", "file"}
+	results, rest, err := ParseOSArgs(options)
+	if err != nil {
+		t.Fatalf("ParseOSArgs() with %q, got error %v", os.Args, err)
+	}
+	if conf, want := configure(results), (config{true, false, "", 10, 0, 0}); conf != want {
+		t.Errorf("ParseOSArgs() with %q, got %v, want %v", os.Args, conf, want)
+	}
+	if !equal(rest, []string{"file"}) {
+		t.Errorf("ParseOSArgs() with %q, got rest %q, want [file]", os.Args, rest)
+	}
+}
+
+func TestHelpRequested(t *testing.T) {
+	var buf bytes.Buffer
+	results, rest, err := ParseWithOutput(options, []string{"", "-a", "--help", "foo"}, &buf)
+
+	e, ok := err.(Error)
+	if !ok || e.Message != ErrHelpRequested {
+		t.Fatalf("ParseWithOutput() error = %v, want %q", err, ErrHelpRequested)
+	}
+
+	if len(results) != 1 || results[0].Long != "amend" {
+		t.Errorf("ParseWithOutput() results = %v, want only --amend", results)
+	}
+
+	if !equal(rest, []string{"foo"}) {
+		t.Errorf("ParseWithOutput() rest = %v, want [foo]", rest)
+	}
+
+	if !strings.Contains(buf.String(), "--help (-h)") {
+		t.Errorf("help output is missing --help:\n%s", buf.String())
+	}
+}
+
+func TestParsePermute(t *testing.T) {
+	table := []struct {
+		args []string
+		conf config
+		rest []string
+	}{
+		{
+			[]string{"", "-a", "file1", "-b", "file2"},
+			config{true, true, "", 0, 0, 0},
+			[]string{"file1", "file2"},
+		},
+		{
+			[]string{"", "file1", "-d", "10", "file2", "-e"},
+			config{false, false, "", 10, 1, 0},
+			[]string{"file1", "file2"},
+		},
+		{
+			[]string{"", "-a", "file1", "--", "-b", "file2"},
+			config{true, false, "", 0, 0, 0},
+			[]string{"file1", "-b", "file2"},
+		},
+		{
+			[]string{"", "-", "-a"},
+			config{true, false, "", 0, 0, 0},
+			[]string{"-"},
+		},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseConfig(options, row.args, Config{Permute: true})
+		if err != nil {
+			t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], rest, row.rest)
+		}
+	}
+}
+
+func TestIgnoreUnknown(t *testing.T) {
+	table := []struct {
+		permute bool
+		args    []string
+		conf    config
+		rest    []string
+	}{
+		{false, []string{"", "--depth=1", "-a", "file"}, config{true, false, "", 0, 0, 0}, []string{"--depth=1", "file"}},
+		{false, []string{"", "-a", "--depth", "1", "-b"}, config{true, false, "", 0, 0, 0}, []string{"--depth", "1", "-b"}},
+		{true, []string{"", "-a", "--depth", "1", "-b"}, config{true, true, "", 0, 0, 0}, []string{"--depth", "1"}},
+		{false, []string{"", "-x", "-b"}, config{false, true, "", 0, 0, 0}, []string{"-x"}},
+		{false, []string{"", "-aZbq", "-e"}, config{true, false, "", 0, 1, 0}, []string{"-Zbq"}},
+		{false, []string{"", "-Z", "--", "-b"}, config{false, false, "", 0, 0, 0}, []string{"-Z", "-b"}},
+	}
+
+	for _, row := range table {
+		conf := Config{IgnoreUnknown: true, Permute: row.permute}
+		results, rest, err := ParseConfig(options, row.args, conf)
+		if err != nil {
+			t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+	}
+
+	// Ambiguous abbreviations are still errors.
+	ambiguous := []Option{
+		{Long: "delay", Kind: KindNone, Help: "delay"},
+		{Long: "depth", Kind: KindNone, Help: "depth"},
+	}
+	_, _, err := ParseConfig(ambiguous, []string{"", "--de"}, Config{IgnoreUnknown: true})
+	if !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("ParseConfig([--de]), got %v, want %q", err, ErrAmbiguous)
+	}
+	if _, _, err := ParseConfig(options, []string{"", "-x"}, Config{}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseConfig([-x]) without IgnoreUnknown, got %v, want %q", err, ErrInvalid)
+	}
+}
+
+func TestStopWhen(t *testing.T) {
+	atBrief := func(result Result) bool { return result.Long == "brief" }
+	never := func(Result) bool { return false }
+
+	table := []struct {
+		stop    func(Result) bool
+		permute bool
+		args    []string
+		conf    config
+		rest    []string
+	}{
+		{atBrief, false, []string{"", "-a", "--brief", "-e", "file"}, config{true, true, "", 0, 0, 0}, []string{"-e", "file"}},
+		{atBrief, false, []string{"", "-ab", "-e"}, config{true, true, "", 0, 0, 0}, []string{"-e"}},
+		{atBrief, false, []string{"", "-bae", "-e"}, config{false, true, "", 0, 0, 0}, []string{"-ae", "-e"}},
+		{atBrief, true, []string{"", "file", "-b", "-a", "--", "x"}, config{false, true, "", 0, 0, 0}, []string{"file", "-a", "--", "x"}},
+		{never, false, []string{"", "-a", "--brief", "-e", "file"}, config{true, true, "", 0, 1, 0}, []string{"file"}},
+		{nil, false, []string{"", "-ab", "-e"}, config{true, true, "", 0, 1, 0}, []string{}},
+	}
+
+	for _, row := range table {
+		conf := Config{StopWhen: row.stop, Permute: row.permute}
+		results, rest, err := ParseConfig(options, row.args, conf)
+		if err != nil {
+			t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+	}
+}
+
+func TestLenientEquals(t *testing.T) {
+	table := []struct {
+		lenient bool
+		args    []string
+		conf    config
+		rest    []string
+	}{
+		{true, []string{"", "--delay", "=", "10", "file"}, config{false, false, "", 10, 0, 0}, []string{"file"}},
+		{true, []string{"", "--delay", "=10", "file"}, config{false, false, "", 10, 0, 0}, []string{"file"}},
+		{true, []string{"", "--color", "=", "auto", "file"}, config{false, false, "auto", 0, 0, 0}, []string{"file"}},
+		{true, []string{"", "--col", "=auto", "-a"}, config{true, false, "auto", 0, 0, 0}, []string{}},
+		{true, []string{"", "--delay", "10", "=x"}, config{false, false, "", 10, 0, 0}, []string{"=x"}},
+		{true, []string{"", "--amend", "=", "x"}, config{true, false, "", 0, 0, 0}, []string{"=", "x"}},
+		{true, []string{"", "-d", "=10"}, config{false, false, "", 0, 0, 0}, []string{}},
+
+		// By default, the "=" isn't special.
+		{false, []string{"", "--delay", "=", "10"}, config{false, false, "", 0, 0, 0}, []string{"10"}},
+		{false, []string{"", "--color", "=auto"}, config{false, false, "", 0, 0, 0}, []string{"=auto"}},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseConfig(options, row.args, Config{LenientEquals: row.lenient})
+		if err != nil {
+			t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+	}
+
+	results, _, _ := ParseConfig(options, []string{"", "--delay", "=", "10"}, Config{})
+	if got, ok := Last(results, "delay", 0); !ok || got != "=" {
+		t.Errorf("ParseConfig([--delay = 10]), got argument %q, want %q", got, "=")
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	table := []struct {
+		args []string
+		conf config
+		rest []string
+		err  error
+	}{
+		{[]string{"", "/a", "/brief", "file"}, config{true, true, "", 0, 0, 0}, []string{"file"}, nil},
+		{[]string{"", "/delay:10", "/e", "/e"}, config{false, false, "", 10, 2, 0}, []string{}, nil},
+		{[]string{"", "/d", "10", "/color:auto", "/c"}, config{false, false, "", 10, 0, 0}, []string{}, nil},
+		{[]string{"", "/del:5", "/col:x"}, config{false, false, "x", 5, 0, 0}, []string{}, nil},
+		{[]string{"", "/a", "-b", "/e"}, config{true, false, "", 0, 0, 0}, []string{"-b", "/e"}, nil},
+		{[]string{"", "/a", "--", "/b"}, config{true, false, "", 0, 0, 0}, []string{"/b"}, nil},
+		{[]string{"", "/", "/a"}, config{false, false, "", 0, 0, 0}, []string{"/", "/a"}, nil},
+		{
+			[]string{"", "/ab"}, config{}, []string{"/ab"},
+			Error{Option: Option{Long: "ab"}, Message: ErrInvalid, Token: "/ab", Index: 1},
+		},
+		{
+			[]string{"", "/a:yes"}, config{}, []string{},
+			Error{Option: options[0], Message: ErrTooMany, Token: "/a:yes", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		conf := Config{Prefix: "/", Separator: ":"}
+		results, rest, err := ParseConfig(options, row.args, conf)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+	}
+
+	// The separator also applies to the usual long options.
+	results, _, err := ParseConfig(options, []string{"", "--delay:10"}, Config{Separator: ":"})
+	if conf := configure(results); err != nil || conf.delay != 10 {
+		t.Errorf("ParseConfig([--delay:10]) with separator, got %v, %v", conf, err)
+	}
+}
+
+func TestPosixlyCorrect(t *testing.T) {
+	args := []string{"", "-a", "file1", "-b", "file2"}
+	table := []struct {
+		env  bool
+		conf config
+		rest []string
+	}{
+		{false, config{true, true, "", 0, 0, 0}, []string{"file1", "file2"}},
+		{true, config{true, false, "", 0, 0, 0}, []string{"file1", "-b", "file2"}},
+	}
+
+	for _, row := range table {
+		// Setenv restores the variable after the test, even
+		// when it's then unset.
+		t.Setenv("POSIXLY_CORRECT", "1")
+		if !row.env {
+			os.Unsetenv("POSIXLY_CORRECT")
+		}
+
+		results, rest, err := ParseConfig(options, args, Config{Permute: true})
+		if err != nil {
+			t.Errorf("ParseConfig(%q) with POSIXLY_CORRECT %v, got error %v", args[1:], row.env, err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("ParseConfig(%q) with POSIXLY_CORRECT %v, got %v, want %v", args[1:], row.env, conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q) with POSIXLY_CORRECT %v, got %v, want %v", args[1:], row.env, rest, row.rest)
+		}
+	}
+}
+
+func TestParseDash(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	extract := Option{Short: 'x', Kind: KindNone, Help: "extract"}
+	dashOptions := []Option{verbose, extract}
+
+	table := []struct {
+		args    []string
+		permute bool
+		results []Result
+		rest    []string
+	}{
+		{[]string{"", "-"}, false, nil, []string{"-"}},
+		{[]string{"", "-"}, true, nil, []string{"-"}},
+		{[]string{"", "-", "file"}, false, nil, []string{"-", "file"}},
+		{[]string{"", "-", "file"}, true, nil, []string{"-", "file"}},
+		{[]string{"", "-v", "-", "-x"}, false, []Result{{Option: verbose}}, []string{"-", "-x"}},
+		{[]string{"", "-v", "-", "-x"}, true, []Result{{Option: verbose}, {Option: extract}}, []string{"-"}},
+		{[]string{"", "-v", "--", "-"}, true, []Result{{Option: verbose}}, []string{"-"}},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseConfig(dashOptions, row.args, Config{Permute: row.permute})
+		if err != nil {
+			t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseConfig(%q) with Permute %v, got %v, want %v", row.args[1:], row.permute, results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q) with Permute %v, got rest %q, want %q", row.args[1:], row.permute, rest, row.rest)
+		}
+	}
+}
+
+func TestParseAbbreviated(t *testing.T) {
+	prefixOptions := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+		{Long: "version", Short: 'V', Kind: KindNone, Help: "print the version"},
+		{Long: "color", Short: 'c', Kind: KindNone, Help: "colorize output"},
+		{Long: "colorscheme", Kind: KindOptional, Help: "use the given color scheme"},
+	}
+
+	table := []struct {
+		arg  string
+		long string
+		err  error
+	}{
+		// unique prefix
+		{"--verb", "verbose", nil},
+		{"--vers", "version", nil},
+		{"--colors=dark", "colorscheme", nil},
+
+		// exact match wins over prefix
+		{"--color", "color", nil},
+
+		// ambiguous prefix
+		{"--ver", "", Error{
+			Option:     Option{Long: "ver"},
+			Message:    ErrAmbiguous,
+			Candidates: []string{"verbose", "version"},
+			Token:      "--ver",
+			Index:      1,
+		}},
+		{"--col", "", Error{
+			Option:     Option{Long: "col"},
+			Message:    ErrAmbiguous,
+			Candidates: []string{"color", "colorscheme"},
+			Token:      "--col",
+			Index:      1,
+		}},
+	}
+
+	for _, row := range table {
+		var buf bytes.Buffer
+		results, _, err := ParseWithOutput(prefixOptions, []string{"", row.arg}, &buf)
+
+		if row.err != nil {
+			if !reflect.DeepEqual(err, row.err) {
+				t.Errorf("ParseWithOutput(%q), got %#v, want %#v", row.arg, err, row.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.arg, err)
+		} else if len(results) != 1 || results[0].Long != row.long {
+			t.Errorf("ParseWithOutput(%q), got %v, want --%s", row.arg, results, row.long)
+		}
+	}
+
+	_, _, err := ParseWithOutput(prefixOptions, []string{"", "--ver"}, nil)
+	want := "ambiguous option: --ver (could be --verbose, --version)"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput(\"--ver\"), got %v, want %q", err, want)
+	}
+}
+
+func TestResultAccessors(t *testing.T) {
+	delay := Option{Long: "delay", Short: 'd', Kind: KindRequired, Help: "delay ARG milliseconds"}
+	color := Option{Long: "color", Short: 'c', Kind: KindOptional, Help: "colorize output"}
+
+	if n, err := (Result{Option: delay, Optarg: "10"}).Int(); err != nil || n != 10 {
+		t.Errorf("Int(\"10\"), got %v, %v, want 10", n, err)
+	}
+	if f, err := (Result{Option: delay, Optarg: "2.5"}).Float64(); err != nil || f != 2.5 {
+		t.Errorf("Float64(\"2.5\"), got %v, %v, want 2.5", f, err)
+	}
+	if d, err := (Result{Option: delay, Optarg: "30s"}).Duration(); err != nil || d != 30*time.Second {
+		t.Errorf("Duration(\"30s\"), got %v, %v, want 30s", d, err)
+	}
+	if d, err := (Result{Option: delay, Optarg: "1h30m"}).Duration(); err != nil || d != 90*time.Minute {
+		t.Errorf("Duration(\"1h30m\"), got %v, %v, want 1h30m", d, err)
+	}
+	if b, err := (Result{Option: color, Optarg: "true"}).Bool(); err != nil || !b {
+		t.Errorf("Bool(\"true\"), got %v, %v, want true", b, err)
+	}
+
+	// Invalid values are reported along with the option.
+	_, err := (Result{Option: delay, Optarg: "ten"}).Int()
+	if err == nil || !strings.Contains(err.Error(), "--delay (-d)") {
+		t.Errorf("Int(\"ten\"), got %v, want an error naming --delay (-d)", err)
+	}
+	_, err = (Result{Option: delay, Optarg: "fast"}).Float64()
+	if err == nil || !strings.Contains(err.Error(), "--delay (-d)") {
+		t.Errorf("Float64(\"fast\"), got %v, want an error naming --delay (-d)", err)
+	}
+	_, err = (Result{Option: delay, Optarg: "soon"}).Duration()
+	if err == nil || !strings.Contains(err.Error(), "--delay (-d)") {
+		t.Errorf("Duration(\"soon\"), got %v, want an error naming --delay (-d)", err)
+	}
+	_, err = (Result{Option: color, Optarg: "maybe"}).Bool()
+	if err == nil || !strings.Contains(err.Error(), "--color (-c)") {
+		t.Errorf("Bool(\"maybe\"), got %v, want an error naming --color (-c)", err)
+	}
+
+	// An optional argument that wasn't supplied is an error, not
+	// a zero value.
+	if _, err := (Result{Option: color, Optarg: ""}).Int(); err == nil {
+		t.Error("Int(\"\") should fail")
+	}
+	if _, err := (Result{Option: color, Optarg: ""}).Float64(); err == nil {
+		t.Error("Float64(\"\") should fail")
+	}
+	if _, err := (Result{Option: color, Optarg: ""}).Duration(); err == nil {
+		t.Error("Duration(\"\") should fail")
+	}
+	if _, err := (Result{Option: color, Optarg: ""}).Bool(); err == nil {
+		t.Error("Bool(\"\") should fail")
+	}
+}
+
+func TestHasArg(t *testing.T) {
+	pager := Option{Long: "pager", Short: 'P', Kind: KindOptional, Help: "page output"}
+	paged := Option{Long: "paged", Short: 'p', Kind: KindOptional, Help: "page output", Default: "more"}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	argOptions := []Option{pager, paged, verbose}
+
+	table := []struct {
+		args []string
+		want Result
+	}{
+		{[]string{"", "--pager"}, Result{Option: pager}},
+		{[]string{"", "--pager="}, Result{Option: pager, HasArg: true}},
+		{[]string{"", "--pager=less"}, Result{Option: pager, Optarg: "less", HasArg: true}},
+		{[]string{"", "-vP"}, Result{Option: pager}},
+		{[]string{"", "-P="}, Result{Option: pager, HasArg: true}},
+		{[]string{"", "-vPless"}, Result{Option: pager, Optarg: "less", HasArg: true}},
+		// a Default replaces the missing argument, but not HasArg
+		{[]string{"", "--paged"}, Result{Option: paged, Optarg: "more"}},
+		{[]string{"", "--paged="}, Result{Option: paged, Optarg: "more", HasArg: true}},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(argOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+			continue
+		}
+		if got := results[len(results)-1]; !reflect.DeepEqual(got, row.want) {
+			t.Errorf("ParseWithOutput(%q), got %+v, want %+v", row.args[1:], got, row.want)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	color := Option{
+		Long:    "color",
+		Short:   'c',
+		Kind:    KindOptional,
+		Help:    "colorize output",
+		Default: "auto",
+	}
+	width := Option{
+		Long:    "width",
+		Short:   'w',
+		Kind:    KindRequired,
+		Help:    "wrap output at ARG columns",
+		Default: "80",
+	}
+	defaultOptions := []Option{color, width}
+
+	table := []struct {
+		args  []string
+		color string
+		width string
+	}{
+		{[]string{"", "--color"}, "auto", "80"},
+		{[]string{"", "-c"}, "auto", "80"},
+		{[]string{"", "--color=never"}, "never", "80"},
+		{[]string{"", "-calways", "-w", "100"}, "always", "100"},
+		{[]string{""}, "auto", "80"},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(defaultOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+			continue
+		}
+		if got := Optarg(results, color); got != row.color {
+			t.Errorf("ParseWithOutput(%q), got color %q, want %q", row.args[1:], got, row.color)
+		}
+		if got := Optarg(results, width); got != row.width {
+			t.Errorf("ParseWithOutput(%q), got width %q, want %q", row.args[1:], got, row.width)
+		}
+	}
+}
+
+func TestAmbiguousSorted(t *testing.T) {
+	ambiguousOptions := []Option{
+		{Long: "count", Kind: KindRequired, Help: "stop after N"},
+		{Long: "compress", Kind: KindNone, Help: "compress output"},
+		{Long: "color", Kind: KindNone, Help: "colorize output"},
+		{Long: "config", Kind: KindRequired, Help: "read FILE"},
+	}
+	want := []string{"color", "compress", "config", "count"}
+
+	// The candidates come out the same however the options are
+	// ordered.
+	for i := 0; i < len(ambiguousOptions); i++ {
+		rotated := append(ambiguousOptions[i:len(ambiguousOptions):len(ambiguousOptions)], ambiguousOptions[:i]...)
+		_, _, err := ParseWithOutput(rotated, []string{"", "--co"}, nil)
+		e, ok := err.(Error)
+		if !ok || e.Message != ErrAmbiguous {
+			t.Errorf("ParseWithOutput([--co]), got %v, want %q", err, ErrAmbiguous)
+			continue
+		}
+		if !reflect.DeepEqual(e.Candidates, want) {
+			t.Errorf("ParseWithOutput([--co]), got candidates %q, want %q", e.Candidates, want)
+		}
+		msg := "ambiguous option: --co (could be --color, --compress, --config, --count)"
+		if e.Error() != msg {
+			t.Errorf("ParseWithOutput([--co]), got %q, want %q", e.Error(), msg)
+		}
+	}
+}
+
+func TestParseRaw(t *testing.T) {
+	host := Option{Long: "host", Short: 'h', Kind: KindRequired}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone}
+	rawOptions := []Option{host, verbose}
+
+	results, rest, err := ParseRaw(rawOptions, []string{"", "-v", "-h", "example.com", "file"})
+	if err != nil {
+		t.Fatalf("ParseRaw(), got error %v", err)
+	}
+	want := []Result{{Option: verbose}, {Option: host, Optarg: "example.com", HasArg: true}}
+	if !reflect.DeepEqual(results, want) || !equal(rest, []string{"file"}) {
+		t.Errorf("ParseRaw(), got %v, %q, want %v, [file]", results, rest, want)
+	}
+
+	// There is no help option to ask for.
+	_, _, err = ParseRaw(rawOptions, []string{"", "--help"})
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseRaw([--help]), got %v, want %q", err, ErrInvalid)
+	}
+	results, _, err = ParseRaw(nil, []string{"", "-h"})
+	if !errors.Is(err, ErrInvalid) || len(results) != 0 {
+		t.Errorf("ParseRaw([-h]) without options, got %v, %v, want %q", results, err, ErrInvalid)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	args := []string{"", "-x", "-axb", "--foo=bar", "--amend=yes", "-d", "5", "file", "-d"}
+	results, rest, errs := ParseAll(options, args)
+
+	wantErrs := []error{
+		Error{Option: Option{Short: 'x'}, Message: ErrInvalid, Token: "-x", Index: 1},
+		Error{Option: Option{Short: 'x'}, Message: ErrInvalid, Token: "-axb", Index: 2},
+		Error{Option: Option{Long: "foo"}, Message: ErrInvalid, Token: "--foo=bar", Index: 3},
+		Error{Option: options[0], Message: ErrTooMany, Token: "--amend=yes", Index: 4},
+	}
+
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Errorf("ParseAll(%q), got errors %v, want %v", args[1:], errs, wantErrs)
+	}
+
+	conf := configure(results)
+	if want := (config{true, true, "", 5, 0, 0}); conf != want {
+		t.Errorf("ParseAll(%q), got %v, want %v", args[1:], conf, want)
+	}
+
+	if !equal(rest, []string{"file", "-d"}) {
+		t.Errorf("ParseAll(%q), got rest %v, want [file -d]", args[1:], rest)
+	}
+
+	_, _, errs = ParseAll(options, []string{"", "-a", "-d"})
+	want := []error{Error{Option: options[3], Message: ErrMissing, Token: "-d", Index: 2}}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("ParseAll(%q), got errors %v, want %v", []string{"-a", "-d"}, errs, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	called := false
+	output := Option{
+		Long:     "output",
+		Short:    'o',
+		Kind:     KindRequired,
+		Help:     "write to FILE",
+		Required: true,
+		Handler: func(string) error {
+			called = true
+			return nil
+		},
+	}
+	quiet := Option{Long: "quiet", Short: 'q', Kind: KindNone, Help: "say nothing"}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "say more"}
+	validateOptions := []Option{output, quiet, verbose}
+
+	table := []struct {
+		args   []string
+		target error
+	}{
+		{[]string{"", "-o", "out", "-v", "file"}, nil},
+		{[]string{"", "--help"}, nil},
+		{[]string{"", "-o", "out", "-x"}, ErrInvalid},
+		{[]string{"", "-v", "-o"}, ErrMissing},
+		{[]string{"", "-v"}, ErrRequired},
+		{[]string{"", "-o", "out", "-qv"}, ErrConflict},
+	}
+
+	conf := Config{Exclusive: [][]string{{"quiet", "verbose"}}}
+	for _, row := range table {
+		errs := ValidateConfig(validateOptions, row.args, conf)
+		if row.target == nil {
+			if len(errs) > 0 {
+				t.Errorf("ValidateConfig(%q), got %v, want no errors", row.args[1:], errs)
+			}
+		} else if len(errs) == 0 || !errors.Is(errs[0], row.target) {
+			t.Errorf("ValidateConfig(%q), got %v, want %q", row.args[1:], errs, row.target)
+		}
+	}
+	if called {
+		t.Error("ValidateConfig() called a Handler")
+	}
+
+	// Every error is collected.
+	errs := ValidateConfig(validateOptions, []string{"", "-x", "--bogus", "-qv"}, conf)
+	if len(errs) != 4 {
+		t.Errorf("ValidateConfig(), got %d errors %v, want 4", len(errs), errs)
+	}
+
+	if err := Validate(validateOptions, []string{"", "-o", "out"}); err != nil {
+		t.Errorf("Validate(), got %v", err)
+	}
+	if err := Validate(validateOptions, []string{"", "-x"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Validate([-x]), got %v, want %q", err, ErrInvalid)
+	}
+}
+
+func TestCount(t *testing.T) {
+	countOptions := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "increase verbosity"},
+		{Long: "quiet", Short: 'q', Kind: KindNone, Help: "decrease verbosity"},
+	}
+
+	table := []struct {
+		args []string
+		want int
+	}{
+		{[]string{""}, 0},
+		{[]string{"", "-vvv"}, 3},
+		{[]string{"", "-v", "-v"}, 2},
+		{[]string{"", "--verbose", "--verbose"}, 2},
+		{[]string{"", "-vqv", "--verbose", "-q"}, 3},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(countOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+			continue
+		}
+		if got := Count(results, "verbose", 'v'); got != row.want {
+			t.Errorf("Count(%q), got %d, want %d", row.args[1:], got, row.want)
+		}
+		if got := Count(results, "", 'v'); got != row.want {
+			t.Errorf("Count(%q) by short form, got %d, want %d", row.args[1:], got, row.want)
+		}
+		if got := Count(results, "verbose", 0); got != row.want {
+			t.Errorf("Count(%q) by long form, got %d, want %d", row.args[1:], got, row.want)
+		}
+	}
+}
+
+func TestDashArguments(t *testing.T) {
+	filter := Option{Long: "filter", Short: 'f', Kind: KindRequired, Help: "filter by PATTERN"}
+	match := Option{Long: "match", Short: 'm', Kind: KindOptional, Help: "match PATTERN"}
+	other := Option{Short: 'o', Kind: KindNone, Help: "other"}
+	dashOptions := []Option{filter, match, other}
+
+	table := []struct {
+		args    []string
+		results []Result
+		rest    []string
+	}{
+		{[]string{"", "--filter", "-foo"}, []Result{{Option: filter, Optarg: "-foo", HasArg: true}}, []string{}},
+		{[]string{"", "--filter=-foo"}, []Result{{Option: filter, Optarg: "-foo", HasArg: true}}, []string{}},
+		{[]string{"", "-f-foo"}, []Result{{Option: filter, Optarg: "-foo", HasArg: true}}, []string{}},
+		{[]string{"", "-f", "-foo"}, []Result{{Option: filter, Optarg: "-foo", HasArg: true}}, []string{}},
+		{[]string{"", "-f", "--"}, []Result{{Option: filter, Optarg: "--", HasArg: true}}, []string{}},
+		{[]string{"", "--filter", "-"}, []Result{{Option: filter, Optarg: "-", HasArg: true}}, []string{}},
+		{[]string{"", "--match=-foo"}, []Result{{Option: match, Optarg: "-foo", HasArg: true}}, []string{}},
+		{[]string{"", "-m-foo"}, []Result{{Option: match, Optarg: "-foo", HasArg: true}}, []string{}},
+		{[]string{"", "--match", "-o"}, []Result{{Option: match}, {Option: other}}, []string{}},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseWithOutput(dashOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseWithOutput(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+	}
+}
+
+func TestClusterRequired(t *testing.T) {
+	extract := Option{Short: 'x', Kind: KindNone, Help: "extract"}
+	file := Option{Short: 'f', Kind: KindRequired, Help: "use FILE"}
+	verbose := Option{Short: 'v', Kind: KindNone, Help: "be verbose"}
+	clusterOptions := []Option{extract, file, verbose}
+
+	table := []struct {
+		args    []string
+		results []Result
+		rest    []string
+		err     error
+	}{
+		{
+			// The rest of the cluster is the argument, even
+			// where it spells other options.
+			[]string{"", "-xfvalue", "rest"},
+			[]Result{{Option: extract}, {Option: file, Optarg: "value", HasArg: true}},
+			[]string{"rest"},
+			nil,
+		},
+		{
+			[]string{"", "-xfv", "-v"},
+			[]Result{{Option: extract}, {Option: file, Optarg: "v", HasArg: true}, {Option: verbose}},
+			[]string{},
+			nil,
+		},
+		{
+			// At the end of the cluster, the next argument
+			// is taken, whatever it looks like.
+			[]string{"", "-xf", "value", "rest"},
+			[]Result{{Option: extract}, {Option: file, Optarg: "value", HasArg: true}},
+			[]string{"rest"},
+			nil,
+		},
+		{
+			[]string{"", "-vxf", "-x"},
+			[]Result{{Option: verbose}, {Option: extract}, {Option: file, Optarg: "-x", HasArg: true}},
+			[]string{},
+			nil,
+		},
+		{
+			[]string{"", "-xf"},
+			[]Result{{Option: extract}},
+			[]string{},
+			Error{Option: file, Message: ErrMissing, Token: "-xf", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseWithOutput(clusterOptions, row.args, nil)
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseWithOutput(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+}
+
+func TestKindString(t *testing.T) {
+	table := []struct {
+		kind Kind
+		want string
+	}{
+		{KindNone, "none"},
+		{KindRequired, "required"},
+		{KindOptional, "optional"},
+		{Kind(7), "Kind(7)"},
+		{Kind(-1), "Kind(-1)"},
+	}
+
+	for _, row := range table {
+		if got := row.kind.String(); got != row.want {
+			t.Errorf("Kind(%d).String(), got %q, want %q", int(row.kind), got, row.want)
+		}
+	}
+	if got := fmt.Sprint(KindRequired); got != "required" {
+		t.Errorf("fmt.Sprint(KindRequired), got %q, want %q", got, "required")
+	}
+}
+
+func TestCountAcrossClusters(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "increase verbosity"}
+	quiet := Option{Long: "quiet", Short: 'q', Kind: KindNone, Help: "decrease verbosity"}
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	countOptions := []Option{verbose, quiet, output}
+
+	args := []string{"", "-vv", "-v", "--verbose", "-qvovout", "--verb"}
+	results, _, err := ParseWithOutput(countOptions, args, nil)
+	if err != nil {
+		t.Fatalf("ParseWithOutput(%q), got error %v", args[1:], err)
+	}
+
+	// Every occurrence gets its own result, in order.
+	want := []Result{
+		{Option: verbose},
+		{Option: verbose},
+		{Option: verbose},
+		{Option: verbose},
+		{Option: quiet},
+		{Option: verbose},
+		{Option: output, Optarg: "vout", HasArg: true},
+		{Option: verbose},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("ParseWithOutput(%q), got %v, want %v", args[1:], results, want)
+	}
+	if got := Count(results, "verbose", 'v'); got != 6 {
+		t.Errorf("Count(%q), got %d, want 6", args[1:], got)
+	}
+}
+
+func TestValues(t *testing.T) {
+	valuesOptions := []Option{
+		{Long: "include", Short: 'I', Kind: KindRequired, Help: "add a search path"},
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+	}
+
+	table := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"", "-v"}, nil},
+		{[]string{"", "-I", "/a"}, []string{"/a"}},
+		{[]string{"", "-I/a", "-v", "--include", "/b", "--include=/c"}, []string{"/a", "/b", "/c"}},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(valuesOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+			continue
+		}
+		if got := Values(results, "include", 'I'); !reflect.DeepEqual(got, row.want) {
+			t.Errorf("Values(%q), got %q, want %q", row.args[1:], got, row.want)
+		}
+		if got := Values(results, "", 'I'); !reflect.DeepEqual(got, row.want) {
+			t.Errorf("Values(%q) by short form, got %q, want %q", row.args[1:], got, row.want)
+		}
+
+		got, ok := Last(results, "include", 0)
+		if len(row.want) == 0 {
+			if ok {
+				t.Errorf("Last(%q), got %q, want none", row.args[1:], got)
+			}
+		} else if want := row.want[len(row.want)-1]; !ok || got != want {
+			t.Errorf("Last(%q), got %q, %v, want %q", row.args[1:], got, ok, want)
+		}
+	}
+}
+
+func TestHasGet(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose", Negatable: true}
+	output := Option{Long: "output", Kind: KindRequired, Help: "write to FILE"}
+	quiet := Option{Short: 'q', Kind: KindNone, Help: "be quiet"}
+	hasOptions := []Option{verbose, output, quiet}
+
+	args := []string{"", "-v", "--output", "a", "-q", "--output=b", "--no-verbose"}
+	results, _, err := ParseWithOutput(hasOptions, args, nil)
+	if err != nil {
+		t.Fatalf("ParseWithOutput(%q), got error %v", args[1:], err)
+	}
+
+	table := []struct {
+		long  string
+		short rune
+		want  Result
+		ok    bool
+	}{
+		{"verbose", 'v', Result{Option: verbose, Negated: true}, true},
+		{"", 'v', Result{Option: verbose, Negated: true}, true},
+		{"output", 0, Result{Option: output, Optarg: "b", HasArg: true}, true},
+		{"", 'q', Result{Option: quiet}, true},
+		{"quiet", 0, Result{}, false},
+		{"help", 'h', Result{}, false},
+		{"", 0, Result{}, false},
+	}
+
+	for _, row := range table {
+		got, ok := Get(results, row.long, row.short)
+		if ok != row.ok || !reflect.DeepEqual(got, row.want) {
+			t.Errorf("Get(%q, %q), got %v, %v, want %v, %v", row.long, row.short, got, ok, row.want, row.ok)
+		}
+		if has := Has(results, row.long, row.short); has != row.ok {
+			t.Errorf("Has(%q, %q), got %v, want %v", row.long, row.short, has, row.ok)
+		}
+	}
+}
+
+func TestResultMap(t *testing.T) {
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	quiet := Option{Short: 'q', Kind: KindNone, Help: "be quiet"}
+	pi := Option{Short: 'π', Kind: KindNone, Help: "3.14"}
+	verbose := Option{Long: "verbose", Kind: KindNone, Help: "be verbose"}
+	mapOptions := []Option{output, quiet, pi, verbose}
+
+	args := []string{"", "-o", "a", "-qπ", "--output=b", "-q"}
+	results, _, err := ParseWithOutput(mapOptions, args, nil)
+	if err != nil {
+		t.Fatalf("ParseWithOutput(%q), got error %v", args[1:], err)
+	}
+
+	want := map[string]Result{
+		"output": {Option: output, Optarg: "b", HasArg: true},
+		"q":      {Option: quiet},
+		"π":      {Option: pi},
+	}
+	got := ResultMap(results)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResultMap(%q), got %v, want %v", args[1:], got, want)
+	}
+	if _, ok := got["verbose"]; ok {
+		t.Errorf("ResultMap(%q) has --verbose, which wasn't given", args[1:])
+	}
+	if got := ResultMap(nil); len(got) != 0 {
+		t.Errorf("ResultMap(nil), got %v, want an empty map", got)
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	define := Option{Long: "define", Short: 'D', Kind: KindRequired, Help: "define NAME=VALUE"}
+	keyOptions := []Option{define, {Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}}
+
+	table := []struct {
+		args []string
+		want map[string]string
+		err  error
+	}{
+		{[]string{""}, map[string]string{}, nil},
+		{
+			[]string{"", "-D", "name=value", "-v", "--define=other=thing", "-Dempty="},
+			map[string]string{"name": "value", "other": "thing", "empty": ""},
+			nil,
+		},
+		{
+			[]string{"", "-Dx=1", "-Dx=2"},
+			map[string]string{"x": "2"},
+			nil,
+		},
+		{
+			[]string{"", "-Dx=1", "-D", "oops"},
+			map[string]string{"x": "1"},
+			Error{Option: define, Message: ErrKeyValue, Optarg: "oops"},
+		},
+		{
+			[]string{"", "-D=x"},
+			map[string]string{},
+			Error{Option: define, Message: ErrKeyValue, Optarg: "x"},
+		},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(keyOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+			continue
+		}
+		got, err := KeyValues(results, "define", 'D')
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("KeyValues(%q), got error %v, want %v", row.args[1:], err, row.err)
+		}
+		if !reflect.DeepEqual(got, row.want) {
+			t.Errorf("KeyValues(%q), got %v, want %v", row.args[1:], got, row.want)
+		}
+	}
+
+	_, err := KeyValues([]Result{{Option: define, Optarg: "oops"}}, "define", 0)
+	if want := `expected key=value for --define (-D): "oops"`; err == nil || err.Error() != want {
+		t.Errorf("KeyValues(), got %v, want %q", err, want)
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	exclusiveOptions := []Option{
+		{Long: "quiet", Short: 'q', Kind: KindNone, Help: "say nothing"},
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "say everything"},
+		{Long: "json", Kind: KindNone, Help: "print JSON"},
+		{Long: "yaml", Kind: KindNone, Help: "print YAML"},
+		{Long: "toml", Kind: KindNone, Help: "print TOML"},
+	}
+	conf := Config{
+		Exclusive: [][]string{
+			{"quiet", "verbose"},
+			{"json", "yaml", "toml"},
+		},
+	}
+
+	table := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"", "-q", "--json"}, nil},
+		{[]string{"", "-vv", "--toml", "--toml"}, nil},
+		{[]string{"", "-q", "-v"}, Error{
+			Option:  exclusiveOptions[0],
+			Message: ErrConflict,
+			Other:   exclusiveOptions[1],
+		}},
+		{[]string{"", "--verbose", "--json", "--quiet"}, Error{
+			Option:  exclusiveOptions[1],
+			Message: ErrConflict,
+			Other:   exclusiveOptions[0],
+		}},
+		{[]string{"", "--toml", "--yaml", "--json"}, Error{
+			Option:  exclusiveOptions[4],
+			Message: ErrConflict,
+			Other:   exclusiveOptions[3],
+		}},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseConfig(exclusiveOptions, row.args, conf)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	_, _, err := ParseConfig(exclusiveOptions, []string{"", "-q", "-v"}, conf)
+	want := "conflicting options: --quiet (-q) and --verbose (-v)"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseConfig([-q -v]), got %v, want %q", err, want)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE", Required: true}
+	input := Option{Long: "input", Kind: KindRequired, Help: "read from FILE", Required: true}
+	verbose := Option{Short: 'v', Kind: KindNone, Help: "be verbose"}
+	requiredOptions := []Option{output, input, verbose}
+
+	_, _, err := ParseWithOutput(requiredOptions, []string{"", "--input=in", "-o", "out"}, nil)
+	if err != nil {
+		t.Errorf("ParseWithOutput(), got error %v with all required options present", err)
+	}
+
+	_, _, err = ParseWithOutput(requiredOptions, []string{"", "-v", "--input", "in"}, nil)
+	want := Error{Option: output, Message: ErrRequired, Missing: []Option{output}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("ParseWithOutput(), got %#v, want %#v", err, want)
+	}
+
+	_, _, err = ParseWithOutput(requiredOptions, []string{"", "-v"}, nil)
+	want = Error{Option: output, Message: ErrRequired, Missing: []Option{output, input}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("ParseWithOutput(), got %#v, want %#v", err, want)
+	}
+	if msg := "missing required options: --output (-o), --input"; err == nil || err.Error() != msg {
+		t.Errorf("ParseWithOutput(), got %v, want %q", err, msg)
+	}
+}
+
+func TestRequires(t *testing.T) {
+	format := Option{Long: "output-format", Kind: KindRequired, Help: "write output as FORMAT"}
+	file := Option{Long: "output-file", Short: 'o', Kind: KindRequired, Help: "write output to FILE"}
+	dependentOptions := []Option{format, file}
+	conf := Config{
+		Requires: map[string][]string{
+			"output-format": {"output-file"},
+		},
+	}
+
+	table := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{""}, nil},
+		{[]string{"", "-o", "out.txt"}, nil},
+		{[]string{"", "--output-format", "json", "-o", "out.txt"}, nil},
+		{[]string{"", "-o", "out.txt", "--output-format", "json"}, nil},
+		{[]string{"", "--output-format", "json", "--output-format", "yaml"}, Error{
+			Option:  format,
+			Message: ErrDependency,
+			Other:   file,
+		}},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseConfig(dependentOptions, row.args, conf)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	_, _, err := ParseConfig(dependentOptions, []string{"", "--output-format", "json"}, conf)
+	want := "unmet dependency: --output-format requires --output-file (-o)"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseConfig([--output-format json]), got %v, want %q", err, want)
+	}
+
+	_, _, errs := ParseAll(dependentOptions, []string{"", "--output-format", "json", "--output-format", "yaml"})
+	if len(errs) != 0 {
+		t.Errorf("ParseAll() without Config, got errors %v", errs)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	token := Option{Long: "token", Short: 't', Kind: KindRequired, Help: "authenticate with TOKEN", Env: "GOPTPARSE_TEST_TOKEN"}
+	envOptions := []Option{token}
+
+	table := []struct {
+		env     string
+		set     bool
+		args    []string
+		results []Result
+	}{
+		// neither set; these rows come first, since Setenv
+		// can't unset the variable again
+		{"", false, []string{""}, nil},
+		{"", false, []string{"", "--token", "cli"}, []Result{{Option: token, Optarg: "cli", HasArg: true}}},
+		// env only
+		{"secret", true, []string{""}, []Result{{Option: token, Optarg: "secret", HasArg: true}}},
+		// command line overrides env
+		{"secret", true, []string{"", "-t", "override"}, []Result{{Option: token, Optarg: "override", HasArg: true}}},
+	}
+
+	for _, row := range table {
+		if row.set {
+			t.Setenv(token.Env, row.env)
+		}
+
+		results, _, err := ParseWithOutput(envOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q) with %s=%q, got %v, want %v",
+				row.args[1:], token.Env, row.env, results, row.results)
+		}
+	}
+
+	// A KindNone option's variable holds a boolean.
+	debug := Option{Long: "debug", Kind: KindNone, Help: "print debugging output", Env: "DBG"}
+	color := Option{Long: "color", Kind: KindNone, Help: "colorize output", Env: "COLOR", Negatable: true}
+	boolOptions := []Option{debug, color}
+
+	boolTable := []struct {
+		env     string
+		results []Result
+	}{
+		{"1", []Result{{Option: debug}, {Option: color}}},
+		{"true", []Result{{Option: debug}, {Option: color}}},
+		{"0", []Result{{Option: color, Negated: true}}},
+		{"false", []Result{{Option: color, Negated: true}}},
+		{"", []Result{{Option: color, Negated: true}}},
+	}
+
+	for _, row := range boolTable {
+		t.Setenv("DBG", row.env)
+		t.Setenv("COLOR", row.env)
+		results, _, err := ParseWithOutput(boolOptions, []string{""}, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput() with DBG=%q, got error %v", row.env, err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput() with DBG=%q, got %v, want %v", row.env, results, row.results)
+		}
+		on, ok := Get(results, "color", 0)
+		if b, _ := on.Bool(); !ok || b != (row.env == "1" || row.env == "true") {
+			t.Errorf("ParseWithOutput() with COLOR=%q, got --color %v", row.env, b)
+		}
+	}
+
+	t.Setenv("DBG", "maybe")
+	_, _, err := ParseWithOutput(boolOptions, []string{""}, nil)
+	if e, ok := err.(Error); !ok || e.Message != ErrValue || e.Long != "debug" {
+		t.Errorf("ParseWithOutput() with DBG=maybe, got %v, want %q", err, ErrValue)
+	}
+
+	// A variable never conflicts with the command line, which
+	// leaves it out instead.
+	verbose := Option{Long: "verbose", Kind: KindNone, Help: "be verbose"}
+	quiet := Option{Long: "quiet", Kind: KindNone, Help: "be quiet", Env: "APP_QUIET"}
+	t.Setenv("APP_QUIET", "1")
+	conf := Config{Output: &bytes.Buffer{}, Exclusive: [][]string{{"verbose", "quiet"}}}
+	exclusiveTable := []struct {
+		args    []string
+		results []Result
+	}{
+		{[]string{""}, []Result{{Option: quiet}}},
+		{[]string{"", "--verbose"}, []Result{{Option: verbose}}},
+	}
+	for _, row := range exclusiveTable {
+		results, _, err := ParseConfig([]Option{verbose, quiet}, row.args, conf)
+		if err != nil {
+			t.Errorf("ParseConfig(%q) with APP_QUIET=1, got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseConfig(%q) with APP_QUIET=1, got %v, want %v", row.args[1:], results, row.results)
+		}
+	}
+}
+
+func TestHelpFormatter(t *testing.T) {
+	var captured []Option
+	conf := Config{
+		Output: &bytes.Buffer{},
+		HelpFormatter: func(options []Option) string {
+			captured = options
+			return fmt.Sprintf("%d options\n", len(options))
+		},
+	}
+
+	_, _, err := ParseConfig(options, []string{"", "--help"}, conf)
+	if e, ok := err.(Error); !ok || e.Message != ErrHelpRequested {
+		t.Fatalf("ParseConfig(--help), got %v, want %q", err, ErrHelpRequested)
+	}
+
+	want := append(append([]Option{}, options...), Option{
+		Long:  "help",
+		Short: 'h',
+		Kind:  KindNone,
+		Help:  "Print this help message",
+	})
+	if !reflect.DeepEqual(captured, want) {
+		t.Errorf("HelpFormatter called with %v, want %v", captured, want)
+	}
+
+	if got := conf.Output.(*bytes.Buffer).String(); got != "9 options\n" {
+		t.Errorf("help output is %q, want %q", got, "9 options\n")
+	}
+
+	if got, want := FormatHelp(options[:1]), "\n"+fmt.Sprintf("--amend (-a)  %-50s\n", "amend a foo")+"\n"; got != want {
+		t.Errorf("FormatHelp(), got %q, want %q", got, want)
+	}
+}
+
+func TestMetavar(t *testing.T) {
+	table := []struct {
+		option Option
+		want   string
+	}{
+		{Option{Long: "output", Short: 'o', Kind: KindRequired, Metavar: "FILE"}, "--output=FILE (-o FILE)"},
+		{Option{Long: "output", Short: 'o', Kind: KindRequired}, "--output=OUTPUT (-o OUTPUT)"},
+		{Option{Long: "output", Kind: KindRequired, Metavar: "FILE"}, "--output=FILE"},
+		{Option{Short: 'n', Kind: KindRequired}, "-n ARG"},
+		{Option{Long: "color", Short: 'c', Kind: KindOptional, Metavar: "WHEN"}, "--color[=WHEN] (-c[WHEN])"},
+		{Option{Long: "color", Kind: KindOptional}, "--color[=COLOR]"},
+		{Option{Short: 'c', Kind: KindOptional}, "-c[ARG]"},
+		{Option{Long: "amend", Short: 'a', Kind: KindNone}, "--amend (-a)"},
+	}
+
+	for _, row := range table {
+		if got := computeFlagDesc(row.option); got != row.want {
+			t.Errorf("computeFlagDesc(%+v), got %q, want %q", row.option, got, row.want)
+		}
+	}
+
+	usageOptions := []Option{{Long: "output", Short: 'o', Kind: KindRequired, Metavar: "FILE"}}
+	if got, want := Usage("prog", usageOptions), "usage: prog [-o FILE] [ARGS...]"; got != want {
+		t.Errorf("Usage(), got %q, want %q", got, want)
+	}
+}
+
+// manyOptions returns n options with distinct long and short forms,
+// along with an argument vector using every one of them.
+func manyOptions(n int) ([]Option, []string) {
+	options := make([]Option, n)
+	args := []string{""}
+	for i := range options {
+		options[i] = Option{
+			Long:  fmt.Sprintf("option-%03d", i),
+			Short: rune(0x100 + i),
+			Kind:  KindNone,
+			Help:  "an option",
+		}
+		args = append(args, "--"+options[i].Long, fmt.Sprintf("-%c", options[i].Short))
+	}
+	return options, args
+}
+
+func BenchmarkParseManyOptions(b *testing.B) {
+	options, args := manyOptions(200)
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ParseWithOutput(options, args, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParseResults measures the allocations made while parsing a
+// long command line, with the options set up once beforehand, so that
+// growing the results dominates.
+func BenchmarkParseResults(b *testing.B) {
+	options, args := manyOptions(200)
+	p := Parser{Config: Config{Output: io.Discard}}
+	if err := p.Init(options); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := p.Parse(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The lookups below compare the linear scans with the parser's index,
+// resolving every option once per iteration.
+func BenchmarkLookupLinear(b *testing.B) {
+	options, _ := manyOptions(200)
+	for i := 0; i < b.N; i++ {
+		for _, option := range options {
+			findLong(options, option.Long)
+			findShort(options, option.Short)
+		}
+	}
+}
+
+func BenchmarkLookupIndexed(b *testing.B) {
+	options, _ := manyOptions(200)
+	p := newParser(options, nil)
+	for i := 0; i < b.N; i++ {
+		for _, option := range options {
+			p.findLong(option.Long)
+			p.findShort(option.Short)
+		}
+	}
+}
+
+// listValue is a Value collecting comma-separated items, across any
+// number of occurrences of its option.
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if item == "" {
+			return fmt.Errorf("empty item in %q", s)
+		}
+		*l = append(*l, item)
+	}
+	return nil
+}
+
+func ExampleValue() {
+	var tags listValue
+	options := []Option{
+		{Long: "tags", Short: 't', Kind: KindRequired, Help: "comma-separated tags", Value: &tags},
+	}
+
+	_, _, err := ParseWithOutput(options, []string{"", "--tags", "a,b", "-tc"}, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(tags.String())
+	// Output: a,b,c
+}
+
+func TestValue(t *testing.T) {
+	var tags listValue
+	tagsOption := Option{Long: "tags", Short: 't', Kind: KindRequired, Help: "comma-separated tags", Value: &tags}
+	valueOptions := []Option{tagsOption}
+
+	results, _, err := ParseWithOutput(valueOptions, []string{"", "--tags=x", "-t", "y,z"}, nil)
+	if err != nil {
+		t.Fatalf("ParseWithOutput(), got error %v", err)
+	}
+	if !equal(tags, []string{"x", "y", "z"}) {
+		t.Errorf("Set was not called for every occurrence, got %v", tags)
+	}
+	if len(results) != 2 {
+		t.Errorf("ParseWithOutput(), got %v, want two results", results)
+	}
+
+	tags = nil
+	results, _, err = ParseWithOutput(valueOptions, []string{"", "-t", "x", "-t", "y,,z", "-t", "w"}, nil)
+	e, ok := err.(Error)
+	if !ok || e.Message != ErrValue || e.Err == nil {
+		t.Fatalf("ParseWithOutput(), got %v, want %q", err, ErrValue)
+	}
+	if want := `invalid argument for --tags (-t): empty item in "y,,z"`; err.Error() != want {
+		t.Errorf("ParseWithOutput(), got %q, want %q", err.Error(), want)
+	}
+	if len(results) != 1 {
+		t.Errorf("ParseWithOutput(), got %v, want only the first result", results)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var calls []string
+	record := func(name string) func(string) error {
+		return func(optarg string) error {
+			calls = append(calls, name+"="+optarg)
+			return nil
+		}
+	}
+	errLevel := errors.New("level out of range")
+	handlerOptions := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose", Handler: record("verbose")},
+		{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE", Handler: record("output")},
+		{Long: "level", Kind: KindRequired, Help: "log at LEVEL", Handler: func(optarg string) error {
+			if optarg != "debug" && optarg != "info" {
+				return errLevel
+			}
+			calls = append(calls, "level="+optarg)
+			return nil
+		}},
+		{Long: "quiet", Short: 'q', Kind: KindNone, Help: "say nothing"},
+	}
+
+	results, _, err := ParseWithOutput(handlerOptions, []string{"", "-vq", "-oout", "--level", "info", "-v"}, nil)
+	if err != nil {
+		t.Fatalf("ParseWithOutput(), got error %v", err)
+	}
+	if want := []string{"verbose=", "output=out", "level=info", "verbose="}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("ParseWithOutput(), got calls %q, want %q", calls, want)
+	}
+	if len(results) != 5 {
+		t.Errorf("ParseWithOutput(), got %d results, want 5", len(results))
+	}
+
+	// An error stops parsing right away.
+	calls = nil
+	results, rest, err := ParseWithOutput(handlerOptions, []string{"", "-v", "--level=trace", "-v", "file"}, nil)
+	if !errors.Is(err, ErrHandler) || !errors.Is(err, errLevel) {
+		t.Errorf("ParseWithOutput(), got %v, want %q wrapping %q", err, ErrHandler, errLevel)
+	}
+	want := "option handler failed for --level: level out of range"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput(), got %v, want %q", err, want)
+	}
+	if !reflect.DeepEqual(calls, []string{"verbose="}) || len(results) != 1 || !equal(rest, []string{"-v", "file"}) {
+		t.Errorf("ParseWithOutput(), got calls %q, results %v, rest %q", calls, results, rest)
+	}
+}
+
+func TestNegatable(t *testing.T) {
+	color := Option{Long: "color", Short: 'c', Kind: KindNone, Help: "colorize output", Negatable: true}
+	negatableOptions := []Option{
+		color,
+		{Long: "no-cache", Kind: KindNone, Help: "skip the cache"},
+		{Long: "cache", Kind: KindNone, Help: "use the cache"},
+	}
+
+	table := []struct {
+		args    []string
+		results []Result
+		err     error
+	}{
+		{[]string{"", "--color"}, []Result{{Option: color}}, nil},
+		{[]string{"", "--no-color"}, []Result{{Option: color, Negated: true}}, nil},
+		{[]string{"", "-c", "--no-color"}, []Result{{Option: color}, {Option: color, Negated: true}}, nil},
+		{[]string{"", "--no-color=yes"}, nil, Error{Option: color, Message: ErrTooMany, Token: "--no-color=yes", Index: 1}},
+		// only Negatable options get a "no-" form
+		{[]string{"", "--no-cache"}, []Result{{Option: negatableOptions[1]}}, nil},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(negatableOptions, row.args, nil)
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got error %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	_, _, err := ParseWithOutput(negatableOptions[1:], []string{"", "--no-color"}, nil)
+	if want := (Error{Option: Option{Long: "no-color"}, Message: ErrInvalid, Token: "--no-color", Index: 1}); !reflect.DeepEqual(err, want) {
+		t.Errorf("ParseWithOutput(--no-color) without Negatable, got %v, want %v", err, want)
+	}
+
+	if b, err := (Result{Option: color}).Bool(); err != nil || !b {
+		t.Errorf("Bool() for --color, got %v, %v, want true", b, err)
+	}
+	if b, err := (Result{Option: color, Negated: true}).Bool(); err != nil || b {
+		t.Errorf("Bool() for --no-color, got %v, %v, want false", b, err)
+	}
+
+	if got, want := computeFlagDesc(color), "--[no-]color (-c)"; got != want {
+		t.Errorf("computeFlagDesc(), got %q, want %q", got, want)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	suggestOptions := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+		{Long: "version", Kind: KindNone, Help: "print the version"},
+		{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"},
+	}
+
+	table := []struct {
+		arg        string
+		suggestion string
+	}{
+		{"--verbsoe", "verbose"},
+		{"--versoin", "version"},
+		{"--ouptut=file", "output"},
+		{"--xyzzy", ""},
+		{"--frobnicate", ""},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput(suggestOptions, []string{"", row.arg}, nil)
+		e, ok := err.(Error)
+		if !ok || e.Message != ErrInvalid {
+			t.Errorf("ParseWithOutput(%q), got %v, want %q", row.arg, err, ErrInvalid)
+		} else if e.Suggestion != row.suggestion {
+			t.Errorf("ParseWithOutput(%q), got suggestion %q, want %q", row.arg, e.Suggestion, row.suggestion)
+		}
+	}
+
+	_, _, err := ParseWithOutput(suggestOptions, []string{"", "--verbsoe"}, nil)
+	if want := "invalid option: --verbsoe (did you mean --verbose?)"; err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput(--verbsoe), got %v, want %q", err, want)
+	}
+}
+
+func TestErrorsIs(t *testing.T) {
+	isOptions := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+		{Long: "version", Kind: KindNone, Help: "print the version"},
+		{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE", Required: true},
+		{Long: "quiet", Short: 'q', Kind: KindNone, Help: "say nothing"},
+		{Long: "format", Kind: KindRequired, Help: "write as FORMAT"},
+		{Long: "tags", Kind: KindRequired, Help: "tag with LIST", Value: &listValue{}},
+	}
+	conf := Config{
+		Output:    &bytes.Buffer{},
+		Exclusive: [][]string{{"verbose", "quiet"}},
+		Requires:  map[string][]string{"format": {"output"}},
+	}
+
+	table := []struct {
+		options []Option
+		args    []string
+		target  error
+	}{
+		{isOptions, []string{"", "-x"}, ErrInvalid},
+		{isOptions, []string{"", "-o"}, ErrMissing},
+		{isOptions, []string{"", "--verbose=yes"}, ErrTooMany},
+		{isOptions, []string{"", "--ver"}, ErrAmbiguous},
+		{isOptions, []string{"", "-o", "out", "-v", "-q"}, ErrConflict},
+		{isOptions, []string{"", "-v"}, ErrRequired},
+		{isOptions, []string{"", "--format", "json", "-o", "out"}, nil},
+		{isOptions, []string{"", "--tags", ",", "-o", "out"}, ErrValue},
+		{isOptions, []string{"", "--help"}, ErrHelpRequested},
+		{[]Option{{Long: "help", Help: "help"}}, []string{""}, ErrHelpRedefined},
+		{[]Option{{Long: "empty"}}, []string{""}, ErrHelpMissing},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseConfig(row.options, row.args, conf)
+		if row.target == nil {
+			if err != nil {
+				t.Errorf("ParseConfig(%q), got %v, want no error", row.args[1:], err)
+			}
+			continue
+		}
+		if !errors.Is(err, row.target) {
+			t.Errorf("ParseConfig(%q), got %v, want errors.Is(err, %q)", row.args[1:], err, row.target)
+		}
+		if row.target != ErrInvalid && errors.Is(err, ErrInvalid) {
+			t.Errorf("ParseConfig(%q), got %v, which should not match %q", row.args[1:], err, ErrInvalid)
+		}
+	}
+
+	_, _, err := ParseConfig(isOptions, []string{"", "--format", "json"}, conf)
+	if !errors.Is(err, ErrDependency) {
+		t.Errorf("ParseConfig([--format json]), got %v, want errors.Is(err, %q)", err, ErrDependency)
+	}
+
+	_, _, _, err = ParseCommands(nil, nil, []string{"", "nope"})
+	if !errors.Is(err, ErrCommand) {
+		t.Errorf("ParseCommands([nope]), got %v, want errors.Is(err, %q)", err, ErrCommand)
+	}
+
+	// The cause of an ErrValue is reachable too.
+	cause := errors.New("bad value")
+	err = Error{Option: isOptions[5], Message: ErrValue, Err: cause}
+	if !errors.Is(err, cause) || !errors.Is(err, ErrValue) {
+		t.Errorf("errors.Is(%v), should match both %q and its cause", err, ErrValue)
+	}
+
+	// The rendered text is unchanged.
+	err = Error{Option: Option{Long: "foo"}, Message: ErrInvalid}
+	if want := "invalid option: --foo"; err.Error() != want {
+		t.Errorf("Error(), got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestErrorToken(t *testing.T) {
+	table := []struct {
+		args  []string
+		token string
+	}{
+		{[]string{"", "-a", "-x"}, "-x"},
+		{[]string{"", "-abx"}, "-abx"},
+		{[]string{"", "--verbsoe"}, "--verbsoe"},
+		{[]string{"", "--amend=yes"}, "--amend=yes"},
+		{[]string{"", "-a", "-d"}, "-d"},
+		{[]string{"", "-ad"}, "-ad"},
+		{[]string{"", "--delay"}, "--delay"},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput(options, row.args, nil)
+		e, ok := err.(Error)
+		if !ok {
+			t.Errorf("ParseWithOutput(%q), got %v, want an Error", row.args[1:], err)
+			continue
+		}
+		if e.Token != row.token {
+			t.Errorf("ParseWithOutput(%q), got Token %q, want %q", row.args[1:], e.Token, row.token)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	Messages = map[error]string{
+		ErrInvalid:  "option invalide",
+		ErrMissing:  "l'option exige un argument",
+		ErrTooMany:  "l'option ne prend pas d'argument",
+		ErrRequired: "options obligatoires manquantes",
+	}
+	defer func() { Messages = nil }()
+
+	table := []struct {
+		args   []string
+		target error
+		want   string
+	}{
+		{[]string{"", "-x"}, ErrInvalid, "option invalide: -x"},
+		{[]string{"", "--delay"}, ErrMissing, "l'option exige un argument: --delay (-d)"},
+		{[]string{"", "--amend=oui"}, ErrTooMany, "l'option ne prend pas d'argument: --amend (-a)"},
+		// categories without a translation are left alone
+		{[]string{"", "--ver"}, ErrAmbiguous, "ambiguous option: --ver (could be --verbose, --version)"},
+		// and so is the wording around the category
+		{[]string{"", "--amendd"}, ErrInvalid, "option invalide: --amendd (did you mean --amend?)"},
+	}
+
+	prefixOptions := append(options[:len(options):len(options)],
+		Option{Long: "verbose", Kind: KindNone, Help: "be verbose"},
+		Option{Long: "version", Kind: KindNone, Help: "print the version"},
+	)
+	for _, row := range table {
+		_, _, err := ParseWithOutput(prefixOptions, row.args, nil)
+		if !errors.Is(err, row.target) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %q", row.args[1:], err, row.target)
+		}
+		if err == nil || err.Error() != row.want {
+			t.Errorf("ParseWithOutput(%q), got %v, want %q", row.args[1:], err, row.want)
+		}
+	}
+}
+
+func TestChoices(t *testing.T) {
+	mode := Option{Long: "mode", Short: 'm', Kind: KindRequired, Help: "run in MODE", Choices: []string{"fast", "slow", "auto"}}
+	level := Option{Long: "level", Kind: KindOptional, Help: "compress at LEVEL", Choices: []string{"1", "9"}}
+	name := Option{Long: "name", Kind: KindRequired, Help: "use NAME"}
+	choiceOptions := []Option{mode, level, name}
+
+	table := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"", "--mode", "fast"}, nil},
+		{[]string{"", "-mauto", "--level"}, nil},
+		{[]string{"", "--level=9"}, nil},
+		// no Choices means no restriction
+		{[]string{"", "--name", "anything"}, nil},
+		{[]string{"", "--mode", "medium"}, Error{
+			Option:     mode,
+			Message:    ErrChoice,
+			Candidates: mode.Choices,
+			Optarg:     "medium",
+			Token:      "--mode",
+			Index:      1,
+		}},
+		// choices are case sensitive
+		{[]string{"", "-m", "FAST"}, Error{
+			Option:     mode,
+			Message:    ErrChoice,
+			Candidates: mode.Choices,
+			Optarg:     "FAST",
+			Token:      "-m",
+			Index:      1,
+		}},
+		// an explicitly empty argument is checked like any other
+		{[]string{"", "--mode="}, Error{
+			Option:     mode,
+			Message:    ErrChoice,
+			Candidates: mode.Choices,
+			Optarg:     "",
+			Token:      "--mode=",
+			Index:      1,
+		}},
+		{[]string{"", "--mode", ""}, Error{
+			Option:     mode,
+			Message:    ErrChoice,
+			Candidates: mode.Choices,
+			Optarg:     "",
+			Token:      "--mode",
+			Index:      1,
+		}},
+		{[]string{"", "--level="}, Error{
+			Option:     level,
+			Message:    ErrChoice,
+			Candidates: level.Choices,
+			Optarg:     "",
+			Token:      "--level=",
+			Index:      1,
+		}},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput(choiceOptions, row.args, nil)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	_, _, err := ParseWithOutput(choiceOptions, []string{"", "--mode", "medium"}, nil)
+	want := `invalid choice for --mode (-m): "medium" (choose from fast, slow, auto)`
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput([--mode medium]), got %v, want %q", err, want)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tag := Option{Long: "tag", Short: 't', Kind: KindRequired, Help: "label with TAG", Pattern: "^[a-z0-9-]+$"}
+	patternOptions := []Option{tag}
+
+	table := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"", "--tag", "release-1"}, nil},
+		{[]string{"", "-tv2"}, nil},
+		{[]string{"", "--tag=Release"}, Error{Option: tag, Message: ErrPattern, Optarg: "Release", Token: "--tag=Release", Index: 1}},
+		{[]string{"", "-t", "a b"}, Error{Option: tag, Message: ErrPattern, Optarg: "a b", Token: "-t", Index: 1}},
+		{[]string{"", "--tag="}, Error{Option: tag, Message: ErrPattern, Optarg: "", Token: "--tag=", Index: 1}},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput(patternOptions, row.args, nil)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	_, _, err := ParseWithOutput(patternOptions, []string{"", "--tag=Release"}, nil)
+	want := `argument does not match pattern for --tag (-t): "Release" (must match ^[a-z0-9-]+$)`
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput([--tag=Release]), got %v, want %q", err, want)
+	}
+
+	bad := Option{Long: "tag", Kind: KindRequired, Help: "label with TAG", Pattern: "[a-z"}
+	_, _, err = ParseWithOutput([]Option{bad}, []string{""}, nil)
+	if e, ok := err.(Error); !ok || e.Message != ErrBadPattern || e.Err == nil {
+		t.Errorf("ParseWithOutput() with a bad pattern, got %v, want %q", err, ErrBadPattern)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	table := []struct {
+		options []Option
+		err     error
+	}{
+		{[]Option{verbose, {Long: "version", Short: 'V', Help: "show version"}}, nil},
+		{[]Option{verbose, {Long: "version", Short: 'v', Help: "show version"}}, Error{Option: Option{Short: 'v'}, Message: ErrDuplicate}},
+		{[]Option{verbose, {Long: "verbose", Help: "be loud"}}, Error{Option: Option{Long: "verbose"}, Message: ErrDuplicate}},
+		{[]Option{{Long: "color", Aliases: []string{"colour"}, Help: "use color"}, {Long: "colour", Help: "use colour"}}, Error{Option: Option{Long: "colour"}, Message: ErrDuplicate}},
+		{[]Option{{Long: "color", Negatable: true, Help: "use color"}, {Long: "no-color", Help: "use no color"}}, Error{Option: Option{Long: "no-color"}, Message: ErrDuplicate}},
+		{[]Option{{Long: "no-colour", Help: "use no colour"}, {Long: "color", Aliases: []string{"colour"}, Negatable: true, Help: "use color"}}, Error{Option: Option{Long: "no-colour"}, Message: ErrDuplicate}},
+		{[]Option{{Long: "color", Help: "use color"}, {Long: "no-color", Help: "use no color"}}, nil},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput(row.options, []string{""}, nil)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%v), got %v, want %v", row.options, err, row.err)
+		}
+	}
+
+	_, _, err := ParseWithOutput(table[1].options, []string{""}, nil)
+	if want := "duplicate option: -v"; err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput() with duplicates, got %v, want %q", err, want)
+	}
+}
+
+func TestArgSetting(t *testing.T) {
+	base := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	with := func(set func(*Option)) Option {
+		option := base
+		set(&option)
+		return option
+	}
+
+	table := []struct {
+		option Option
+		field  string
+	}{
+		{base, ""},
+		{with(func(o *Option) { o.Env = "VERBOSE"; o.Negatable = true }), ""},
+		{with(func(o *Option) { o.Kind = KindRequired; o.Metavar = "LEVEL"; o.Default = "1" }), ""},
+		{with(func(o *Option) { o.Metavar = "LEVEL" }), "Metavar"},
+		{with(func(o *Option) { o.Default = "1" }), "Default"},
+		{with(func(o *Option) { o.Choices = []string{"1", "2"} }), "Choices"},
+		{with(func(o *Option) { o.Pattern = "^[0-9]$" }), "Pattern"},
+		{with(func(o *Option) { o.Range = &Range{Min: 1, Max: 2} }), "Range"},
+		{with(func(o *Option) { o.MustExist = true }), "MustExist"},
+		{with(func(o *Option) { o.NArgs = 2 }), "NArgs"},
+		{with(func(o *Option) { o.NoAttached = true }), "NoAttached"},
+		{with(func(o *Option) { o.Complete = func(string) []string { return nil } }), "Complete"},
+		{with(func(o *Option) { o.Normalize = strings.ToLower }), "Normalize"},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput([]Option{row.option}, []string{""}, nil)
+		if row.field == "" {
+			if err != nil {
+				t.Errorf("ParseWithOutput() with %+v, got error %v", row.option, err)
+			}
+			continue
+		}
+		want := "argument setting on an option that takes none for --verbose (-v): " + row.field + " is set"
+		if !errors.Is(err, ErrArgSetting) || err.Error() != want {
+			t.Errorf("ParseWithOutput() with %+v, got %v, want %q", row.option, err, want)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	threads := Option{Long: "threads", Short: 'j', Kind: KindRequired, Help: "use N threads", Range: &Range{Min: 1, Max: 64}}
+	rangeOptions := []Option{threads}
+
+	table := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"", "--threads", "1"}, nil},
+		{[]string{"", "-j64"}, nil},
+		{[]string{"", "-j", "0"}, Error{Option: threads, Message: ErrRange, Optarg: "0", Token: "-j", Index: 1}},
+		{[]string{"", "--threads=65"}, Error{Option: threads, Message: ErrRange, Optarg: "65", Token: "--threads=65", Index: 1}},
+		{[]string{"", "--threads=-3"}, Error{Option: threads, Message: ErrRange, Optarg: "-3", Token: "--threads=-3", Index: 1}},
+		{[]string{"", "-j", "many"}, Error{Option: threads, Message: ErrInteger, Optarg: "many", Token: "-j", Index: 1}},
+		{[]string{"", "-j", "2.5"}, Error{Option: threads, Message: ErrInteger, Optarg: "2.5", Token: "-j", Index: 1}},
+		{[]string{"", "--threads="}, Error{Option: threads, Message: ErrInteger, Optarg: "", Token: "--threads=", Index: 1}},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput(rangeOptions, row.args, nil)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	_, _, err := ParseWithOutput(rangeOptions, []string{"", "-j", "100"}, nil)
+	want := "argument out of range for --threads (-j): 100 (must be from 1 to 64)"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput([-j 100]), got %v, want %q", err, want)
+	}
+	_, _, err = ParseWithOutput(rangeOptions, []string{"", "-j", "many"}, nil)
+	want = `invalid integer for --threads (-j): "many"`
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput([-j many]), got %v, want %q", err, want)
+	}
+}
+
+func TestNArgs(t *testing.T) {
+	point := Option{Long: "point", Short: 'p', Kind: KindRequired, Help: "plot X and Y", NArgs: 2}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	nargsOptions := []Option{point, verbose}
+
+	table := []struct {
+		args    []string
+		results []Result
+		rest    []string
+		err     error
+	}{
+		{
+			[]string{"", "--point", "1", "2", "file"},
+			[]Result{{Option: point, Optarg: "1", HasArg: true, Optargs: []string{"1", "2"}}},
+			[]string{"file"},
+			nil,
+		},
+		{
+			[]string{"", "-vp", "-1", "-v", "-v"},
+			[]Result{
+				{Option: verbose},
+				{Option: point, Optarg: "-1", HasArg: true, Optargs: []string{"-1", "-v"}},
+				{Option: verbose},
+			},
+			[]string{},
+			nil,
+		},
+		{
+			[]string{"", "--point", "1"},
+			nil,
+			[]string{"1"},
+			Error{Option: point, Message: ErrMissing, Token: "--point", Index: 1},
+		},
+		{
+			[]string{"", "--point=1", "2"},
+			nil,
+			[]string{"2"},
+			Error{Option: point, Message: ErrAttached, Token: "--point=1", Index: 1},
+		},
+		{
+			[]string{"", "-p1", "2"},
+			nil,
+			[]string{"2"},
+			Error{Option: point, Message: ErrAttached, Token: "-p1", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseWithOutput(nargsOptions, row.args, nil)
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseWithOutput(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	// Each argument is validated on its own.
+	ranged := point
+	ranged.Range = &Range{Min: 0, Max: 10}
+	_, _, err := ParseWithOutput([]Option{ranged}, []string{"", "-p", "5", "11"}, nil)
+	want := Error{Option: ranged, Message: ErrRange, Optarg: "11", Token: "-p", Index: 1}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("ParseWithOutput([-p 5 11]), got %v, want %v", err, want)
+	}
+}
+
+func TestMustExist(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "input.txt", "data")
+	missing := filepath.Join(dir, "missing.txt")
+
+	input := Option{Long: "input", Short: 'i', Kind: KindRequired, Help: "read FILE", MustExist: true, PathType: PathFile}
+	outdir := Option{Long: "outdir", Kind: KindRequired, Help: "write into DIR", MustExist: true, PathType: PathDir}
+	path := Option{Long: "any", Kind: KindRequired, Help: "use PATH", MustExist: true}
+	pathOptions := []Option{input, outdir, path}
+
+	table := []struct {
+		args   []string
+		target error
+	}{
+		{[]string{"", "-i", file, "--outdir", dir}, nil},
+		{[]string{"", "--any", file, "--any", dir}, nil},
+		{[]string{"", "-i", missing}, ErrNoPath},
+		{[]string{"", "--any", missing}, ErrNoPath},
+		{[]string{"", "-i", dir}, ErrPathType},
+		{[]string{"", "--outdir", file}, ErrPathType},
+		{[]string{"", "--any="}, ErrNoPath},
+		{[]string{"", "-i", ""}, ErrNoPath},
+	}
+
+	for _, row := range table {
+		_, _, err := ParseWithOutput(pathOptions, row.args, nil)
+		if row.target == nil && err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+		} else if row.target != nil && !errors.Is(err, row.target) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %q", row.args[1:], err, row.target)
+		}
+	}
+
+	_, _, err := ParseWithOutput(pathOptions, []string{"", "-i", missing}, nil)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ParseWithOutput([-i missing]), got %v, want it to wrap %q", err, os.ErrNotExist)
+	}
+	_, _, err = ParseWithOutput(pathOptions, []string{"", "--outdir", file}, nil)
+	want := fmt.Sprintf("path is of the wrong type for --outdir: %q is not a directory", file)
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput([--outdir file]), got %v, want %q", err, want)
+	}
+}
+
+func TestShortAttachedEquals(t *testing.T) {
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	color := Option{Long: "color", Short: 'c', Kind: KindOptional, Help: "colorize output"}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	equalsOptions := []Option{output, color, verbose}
+
+	table := []struct {
+		args    []string
+		results []Result
+	}{
+		{[]string{"", "-o=file"}, []Result{{Option: output, Optarg: "file", HasArg: true}}},
+		{[]string{"", "-ofile"}, []Result{{Option: output, Optarg: "file", HasArg: true}}},
+		{[]string{"", "-o", "file"}, []Result{{Option: output, Optarg: "file", HasArg: true}}},
+		{[]string{"", "-vo=file"}, []Result{{Option: verbose}, {Option: output, Optarg: "file", HasArg: true}}},
+		{[]string{"", "-o==x"}, []Result{{Option: output, Optarg: "=x", HasArg: true}}},
+		{[]string{"", "-o=", "file"}, []Result{{Option: output, HasArg: true}}},
+		{[]string{"", "-c=red"}, []Result{{Option: color, Optarg: "red", HasArg: true}}},
+		{[]string{"", "--output=file"}, []Result{{Option: output, Optarg: "file", HasArg: true}}},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(equalsOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+	}
+}
+
+func TestShortBundledOptional(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	optimize := Option{Long: "optimize", Short: 'O', Kind: KindOptional, Help: "optimize at LEVEL", Default: "1"}
+	extract := Option{Short: 'x', Kind: KindNone, Help: "extract"}
+	bundleOptions := []Option{verbose, optimize, extract}
+
+	table := []struct {
+		args    []string
+		results []Result
+		rest    []string
+	}{
+		{
+			[]string{"", "-vO"},
+			[]Result{{Option: verbose}, {Option: optimize, Optarg: "1"}},
+			[]string{},
+		},
+		{
+			[]string{"", "-vO", "x"},
+			[]Result{{Option: verbose}, {Option: optimize, Optarg: "1"}},
+			[]string{"x"},
+		},
+		{
+			[]string{"", "-vOx"},
+			[]Result{{Option: verbose}, {Option: optimize, Optarg: "x", HasArg: true}},
+			[]string{},
+		},
+		{
+			[]string{"", "-xvO2", "-x"},
+			[]Result{{Option: extract}, {Option: verbose}, {Option: optimize, Optarg: "2", HasArg: true}, {Option: extract}},
+			[]string{},
+		},
+		{
+			[]string{"", "-Ov"},
+			[]Result{{Option: optimize, Optarg: "v", HasArg: true}},
+			[]string{},
+		},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseWithOutput(bundleOptions, row.args, nil)
+		if err != nil {
+			t.Errorf("ParseWithOutput(%q), got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseWithOutput(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+	}
+}
+
+func TestNegativeNumbers(t *testing.T) {
+	offset := Option{Long: "offset", Short: 'o', Kind: KindRequired, Help: "start at OFFSET"}
+	one := Option{Short: '1', Kind: KindNone, Help: "one column per line"}
+	numberOptions := []Option{offset, one}
+
+	table := []struct {
+		args    []string
+		conf    Config
+		results []Result
+		rest    []string
+		err     error
+	}{
+		{
+			[]string{"", "--offset", "-5", "file"},
+			Config{},
+			[]Result{{Option: offset, Optarg: "-5", HasArg: true}},
+			[]string{"file"},
+			nil,
+		},
+		{
+			[]string{"", "-5"},
+			Config{},
+			nil,
+			[]string{"-5"},
+			Error{Option: Option{Short: '5'}, Message: ErrInvalid, Token: "-5", Index: 1},
+		},
+		{
+			[]string{"", "-5", "-o", "2"},
+			Config{NegativeNumbers: true},
+			nil,
+			[]string{"-5", "-o", "2"},
+			nil,
+		},
+		{
+			[]string{"", "-3.14", "-o", "2", "-7e3"},
+			Config{NegativeNumbers: true, Permute: true},
+			[]Result{{Option: offset, Optarg: "2", HasArg: true}},
+			[]string{"-3.14", "-7e3"},
+			nil,
+		},
+		{
+			// -1 is an option, so it wins
+			[]string{"", "-1", "-2"},
+			Config{NegativeNumbers: true},
+			[]Result{{Option: one}},
+			[]string{"-2"},
+			nil,
+		},
+		{
+			// words that strconv.ParseFloat takes as numbers are options
+			[]string{"", "-inf", "f"},
+			Config{NegativeNumbers: true},
+			nil,
+			[]string{"-inf", "f"},
+			Error{Option: Option{Short: 'i'}, Message: ErrInvalid, Token: "-inf", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		row.conf.Output = &bytes.Buffer{}
+		results, rest, err := ParseConfig(numberOptions, row.args, row.conf)
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %v, want %v", row.args[1:], rest, row.rest)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseConfig(%q), got error %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+}
+
+func TestSingleDashLong(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	file := Option{Long: "file", Short: 'f', Kind: KindRequired, Help: "read FILE"}
+	level := Option{Long: "level", Kind: KindRequired, Help: "log at LEVEL"}
+	dashOptions := []Option{verbose, file, level}
+
+	table := []struct {
+		args    []string
+		conf    Config
+		results []Result
+		rest    []string
+		err     error
+	}{
+		{
+			[]string{"", "-verbose", "-level=debug", "--file", "x"},
+			Config{SingleDashLong: true},
+			[]Result{{Option: verbose}, {Option: level, Optarg: "debug", HasArg: true}, {Option: file, Optarg: "x", HasArg: true}},
+			[]string{},
+			nil,
+		},
+		// valid clusters still win, even "-file"
+		{
+			[]string{"", "-vf", "x", "-file"},
+			Config{SingleDashLong: true},
+			[]Result{{Option: verbose}, {Option: file, Optarg: "x", HasArg: true}, {Option: file, Optarg: "ile", HasArg: true}},
+			[]string{},
+			nil,
+		},
+		// abbreviations work too
+		{
+			[]string{"", "-lev", "info", "-verb"},
+			Config{SingleDashLong: true},
+			[]Result{{Option: level, Optarg: "info", HasArg: true}, {Option: verbose}},
+			[]string{},
+			nil,
+		},
+		{
+			[]string{"", "-vx"},
+			Config{SingleDashLong: true},
+			[]Result{{Option: verbose}},
+			[]string{"-vx"},
+			Error{Option: Option{Short: 'x'}, Message: ErrInvalid, Token: "-vx", Index: 1},
+		},
+		// without the mode, -verbose is a cluster
+		{
+			[]string{"", "-verbose"},
+			Config{},
+			[]Result{{Option: verbose}},
+			[]string{"-verbose"},
+			Error{Option: Option{Short: 'e'}, Message: ErrInvalid, Token: "-verbose", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseConfig(dashOptions, row.args, row.conf)
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseConfig(%q), got error %v, want %v", row.args[1:], err, row.err)
+		}
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+	}
+}
+
+// TestConcurrentParse is best run with -race, which reports any data
+// race on the shared options.
+func TestConcurrentParse(t *testing.T) {
+	shared := []Option{
+		{Long: "color", Aliases: []string{"colour"}, Kind: KindRequired, Help: "colorize", Choices: []string{"auto", "never"}},
+		{Long: "name", Short: 'n', Kind: KindRequired, Help: "use NAME", Pattern: "^[a-z]+$"},
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose", Negatable: true},
+	}
+	args := []string{"", "--colour=auto", "-vn", "foo", "--no-verbose", "file"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var p Parser
+			if err := p.Init(shared); err != nil {
+				t.Errorf("Init(), got error %v", err)
+				return
+			}
+			for j := 0; j < 50; j++ {
+				results, rest, err := ParseWithOutput(shared, args, io.Discard)
+				if err != nil || len(results) != 4 || !equal(rest, []string{"file"}) {
+					t.Errorf("ParseWithOutput(%q), got %v, %q, %v", args[1:], results, rest, err)
+					return
+				}
+				if _, _, err := p.Parse(args); err != nil {
+					t.Errorf("Parse(%q), got error %v", args[1:], err)
+					return
+				}
+				FormatHelpWidth(shared, 80)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParser(t *testing.T) {
+	var p Parser
+	p.Output = &bytes.Buffer{}
+	if err := p.Init(options); err != nil {
+		t.Fatalf("Init(), got error %v", err)
+	}
+
+	table := []struct {
+		args []string
+		conf config
+		rest []string
+	}{
+		{[]string{"", "-a", "-d", "10", "foo"}, config{true, false, "", 10, 0, 0}, []string{"foo"}},
+		{[]string{"", "--color=red", "-ee"}, config{false, false, "red", 0, 2, 0}, []string{}},
+		{[]string{"", "-b", "--", "-a"}, config{false, true, "", 0, 0, 0}, []string{"-a"}},
+	}
+
+	for _, row := range table {
+		results, rest, err := p.Parse(row.args)
+		if err != nil {
+			t.Errorf("Parse(%q), got error %v", row.args[1:], err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("Parse(%q), got %v, want %v", row.args[1:], conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("Parse(%q), got %v, want %v", row.args[1:], rest, row.rest)
+		}
+	}
+
+	// The Config can change between calls.
+	p.Permute = true
+	results, rest, err := p.Parse([]string{"", "foo", "-a"})
+	if err != nil || len(results) != 1 || !equal(rest, []string{"foo"}) {
+		t.Errorf("Parse() with Permute, got %v, %v, %v", results, rest, err)
+	}
+
+	p.Reset()
+	if p.parser.args != nil || p.parser.optind != 0 || p.Terminated() {
+		t.Errorf("Reset() left state behind: %+v", p.parser)
+	}
+
+	// Invalid options are reported by Init, and then by Parse.
+	if err := p.Init([]Option{{Long: "help", Help: "help"}}); !errors.Is(err, ErrHelpRedefined) {
+		t.Errorf("Init(), got %v, want %q", err, ErrHelpRedefined)
+	}
+	if _, _, err := p.Parse([]string{""}); !errors.Is(err, ErrHelpRedefined) {
+		t.Errorf("Parse() after failed Init(), got %v, want %q", err, ErrHelpRedefined)
+	}
+
+	// The zero value recognizes only --help.
+	var zero Parser
+	zero.Output = &bytes.Buffer{}
+	if _, _, err := zero.Parse([]string{"", "-h"}); !errors.Is(err, ErrHelpRequested) {
+		t.Errorf("Parse() on zero Parser, got %v, want %q", err, ErrHelpRequested)
+	}
+}
+
+func TestTerminated(t *testing.T) {
+	var p Parser
+	p.Output = &bytes.Buffer{}
+	if err := p.Init(options); err != nil {
+		t.Fatalf("Init(), got error %v", err)
+	}
+
+	table := []struct {
+		args       []string
+		rest       []string
+		terminated bool
+	}{
+		{[]string{"", "file"}, []string{"file"}, false},
+		{[]string{"", "--", "file"}, []string{"file"}, true},
+		{[]string{"", "-a", "--", "--", "-b"}, []string{"--", "-b"}, true},
+		{[]string{"", "-a", "file", "--"}, []string{"file", "--"}, false},
+	}
+
+	for _, row := range table {
+		_, rest, err := p.Parse(row.args)
+		if err != nil {
+			t.Errorf("Parse(%q), got error %v", row.args[1:], err)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("Parse(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+		if got := p.Terminated(); got != row.terminated {
+			t.Errorf("Parse(%q), got Terminated() = %v, want %v", row.args[1:], got, row.terminated)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	table := []struct {
+		permute     bool
+		args        []string
+		positionals []string
+		passthrough []string
+	}{
+		{false, []string{"", "-a", "file"}, []string{"file"}, nil},
+		{false, []string{"", "-a", "--", "child", "--flag"}, []string{}, []string{"child", "--flag"}},
+		{false, []string{"", "-a", "file", "--", "-b"}, []string{"file", "--", "-b"}, nil},
+		{true, []string{"", "file", "-a", "other"}, []string{"file", "other"}, nil},
+		{true, []string{"", "file", "-a", "--", "child", "-b"}, []string{"file"}, []string{"child", "-b"}},
+		{true, []string{"", "-a", "--"}, []string{}, []string{}},
+	}
+
+	for _, row := range table {
+		var p Parser
+		p.Output = &bytes.Buffer{}
+		p.Permute = row.permute
+		if err := p.Init(options); err != nil {
+			t.Fatalf("Init(), got error %v", err)
+		}
+		if _, _, err := p.Parse(row.args); err != nil {
+			t.Errorf("Parse(%q), got error %v", row.args[1:], err)
+		}
+		positionals, passthrough := p.Split()
+		if !equal(positionals, row.positionals) {
+			t.Errorf("Parse(%q), got positionals %q, want %q", row.args[1:], positionals, row.positionals)
+		}
+		if !equal(passthrough, row.passthrough) {
+			t.Errorf("Parse(%q), got passthrough %q, want %q", row.args[1:], passthrough, row.passthrough)
+		}
+	}
+}
+
+func TestTerminator(t *testing.T) {
+	table := []struct {
+		terminator string
+		permute    bool
+		args       []string
+		conf       config
+		rest       []string
+	}{
+		{"", false, []string{"", "-a", "--", "-b"}, config{true, false, "", 0, 0, 0}, []string{"-b"}},
+		{"", false, []string{"", "-a", "END", "-b"}, config{true, false, "", 0, 0, 0}, []string{"END", "-b"}},
+		{"END", false, []string{"", "-a", "END", "-b", "END"}, config{true, false, "", 0, 0, 0}, []string{"-b", "END"}},
+		{"END", false, []string{"", "-a", "--", "-b"}, config{true, false, "", 0, 0, 0}, []string{"-b"}},
+		{"END", true, []string{"", "file", "-a", "END", "-b"}, config{true, false, "", 0, 0, 0}, []string{"file", "-b"}},
+		// An option's argument is never a terminator.
+		{"10", false, []string{"", "-d", "10", "-b"}, config{false, true, "", 10, 0, 0}, []string{}},
+	}
+
+	for _, row := range table {
+		var p Parser
+		p.Output = &bytes.Buffer{}
+		p.Terminator = row.terminator
+		p.Permute = row.permute
+		if err := p.Init(options); err != nil {
+			t.Fatalf("Init(), got error %v", err)
+		}
+
+		results, rest, err := p.Parse(row.args)
+		if err != nil {
+			t.Errorf("Parse(%q) with terminator %q, got error %v", row.args[1:], row.terminator, err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("Parse(%q) with terminator %q, got %v, want %v", row.args[1:], row.terminator, conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("Parse(%q) with terminator %q, got rest %q, want %q", row.args[1:], row.terminator, rest, row.rest)
+		}
+	}
+}
+
+func TestStdinArgs(t *testing.T) {
+	stdin := "file2\n\nmy file3\r\n-b\n"
+	table := []struct {
+		enabled bool
+		args    []string
+		conf    config
+		rest    []string
+	}{
+		{true, []string{"", "-a", "file1", "-"}, config{true, false, "", 0, 0, 0}, []string{"file1", "file2", "my file3", "-b"}},
+		{true, []string{"", "-a", "-"}, config{true, false, "", 0, 0, 0}, []string{"file2", "my file3", "-b"}},
+		{true, []string{"", "-a", "-", "file1"}, config{true, false, "", 0, 0, 0}, []string{"-", "file1"}},
+		{true, []string{"", "-a", "--", "-"}, config{true, false, "", 0, 0, 0}, []string{"file2", "my file3", "-b"}},
+		{false, []string{"", "-a", "file1", "-"}, config{true, false, "", 0, 0, 0}, []string{"file1", "-"}},
+	}
+
+	for _, row := range table {
+		conf := Config{StdinArgs: row.enabled, Stdin: strings.NewReader(stdin)}
+		args := append([]string{}, row.args...)
+		results, rest, err := ParseConfig(options, args, conf)
+		if err != nil {
+			t.Errorf("ParseConfig(%q), got error %v", row.args[1:], err)
+		}
+		if conf := configure(results); conf != row.conf {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], conf, row.conf)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+		if !equal(args, row.args) {
+			t.Errorf("ParseConfig(%q) modified its arguments to %q", row.args[1:], args)
+		}
+	}
+
+	conf := Config{StdinArgs: true, Stdin: iotest.ErrReader(io.ErrUnexpectedEOF)}
+	if _, _, err := ParseConfig(options, []string{"", "-"}, conf); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ParseConfig([-]) with a failing reader, got %v, want %q", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestStopped(t *testing.T) {
+	var p Parser
+	p.Output = &bytes.Buffer{}
+	if err := p.Init(options); err != nil {
+		t.Fatalf("Init(), got error %v", err)
+	}
+
+	table := []struct {
+		args []string
+		want int
+	}{
+		{[]string{""}, 1},
+		{[]string{"", "-a", "-d", "10"}, 4},
+		{[]string{"", "-a", "-d", "10", "file", "-b"}, 4},
+		{[]string{"", "-ab", "--", "-c"}, 3},
+		{[]string{"", "file"}, 1},
+	}
+
+	for _, row := range table {
+		_, rest, err := p.Parse(row.args)
+		if err != nil {
+			t.Errorf("Parse(%q), got error %v", row.args[1:], err)
+		}
+		if got := p.Stopped(); got != row.want {
+			t.Errorf("Parse(%q), got Stopped() = %d, want %d", row.args[1:], got, row.want)
+		}
+		if !equal(row.args[p.Stopped():], rest) {
+			t.Errorf("Parse(%q), got args[Stopped():] = %q, want %q", row.args[1:], row.args[p.Stopped():], rest)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	color := Option{Long: "color", Short: 'c', Kind: KindOptional, Help: "colorize output", Aliases: []string{"colour"}}
+	output := Option{Long: "output", Kind: KindRequired, Help: "write to FILE", Metavar: "FILE", Aliases: []string{"out", "o"}}
+	aliasOptions := []Option{color, output}
+
+	table := []struct {
+		args    []string
+		results []Result
+		err     error
+	}{
+		{[]string{"", "--color=red"}, []Result{{Option: color, Optarg: "red", HasArg: true}}, nil},
+		{[]string{"", "--colour=red"}, []Result{{Option: color, Optarg: "red", HasArg: true}}, nil},
+		{[]string{"", "--output", "a"}, []Result{{Option: output, Optarg: "a", HasArg: true}}, nil},
+		{[]string{"", "--out", "a"}, []Result{{Option: output, Optarg: "a", HasArg: true}}, nil},
+		{[]string{"", "--o", "a"}, []Result{{Option: output, Optarg: "a", HasArg: true}}, nil},
+		// "colo" abbreviates both names of the same option
+		{[]string{"", "--colo"}, []Result{{Option: color}}, nil},
+		{[]string{"", "--colur"}, nil, Error{
+			Option:     Option{Long: "colur"},
+			Message:    ErrInvalid,
+			Suggestion: "color",
+			Token:      "--colur",
+			Index:      1,
+		}},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(aliasOptions, row.args, nil)
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got error %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	help := FormatHelp(aliasOptions)
+	for _, want := range []string{"--color, --colour[=COLOR] (-c[COLOR])", "--output, --out, --o=FILE"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("FormatHelp() is missing %q:\n%s", want, help)
+		}
+	}
+}
+
+func TestHidden(t *testing.T) {
+	visible := Option{Long: "visible", Short: 'v', Kind: KindNone, Help: "a documented option"}
+	hidden := Option{Long: "secret", Short: 'S', Kind: KindNone, Help: "an internal option", Hidden: true}
+	hiddenOptions := []Option{visible, hidden}
+
+	results, _, err := ParseWithOutput(hiddenOptions, []string{"", "-v", "--secret", "-S"}, nil)
+	want := []Result{{Option: visible}, {Option: hidden}, {Option: hidden}}
+	if err != nil || !reflect.DeepEqual(results, want) {
+		t.Errorf("ParseWithOutput(), got %v, %v, want %v", results, err, want)
+	}
+
+	var buf bytes.Buffer
+	ParseWithOutput(hiddenOptions, []string{"", "--help"}, &buf)
+	help := buf.String()
+	if !strings.Contains(help, "--visible (-v)") || !strings.Contains(help, "--help (-h)") {
+		t.Errorf("help output is missing visible options:\n%s", help)
+	}
+	if strings.Contains(help, "secret") {
+		t.Errorf("help output shows a hidden option:\n%s", help)
+	}
+
+	if got, want := Usage("prog", hiddenOptions), "usage: prog [-v] [ARGS...]"; got != want {
+		t.Errorf("Usage(), got %q, want %q", got, want)
+	}
+
+	// Hidden options must be given in full, and are never
+	// suggested, nor make a visible prefix ambiguous.
+	debug := Option{Long: "debug", Kind: KindNone, Help: "print debugging output"}
+	internal := Option{Long: "debug-internal", Kind: KindNone, Help: "debug the parser", Hidden: true}
+	debugOptions := []Option{debug, internal, hidden}
+	results, _, err = ParseWithOutput(debugOptions, []string{"", "--debu", "--debug-internal"}, nil)
+	want = []Result{{Option: debug}, {Option: internal}}
+	if err != nil || !reflect.DeepEqual(results, want) {
+		t.Errorf("ParseWithOutput() with a hidden prefix, got %v, %v, want %v", results, err, want)
+	}
+	for _, arg := range []string{"--debug-int", "--secre", "--sekret"} {
+		_, _, err := ParseWithOutput(debugOptions, []string{"", arg}, nil)
+		if !errors.Is(err, ErrInvalid) || strings.Contains(err.Error(), "did you mean") {
+			t.Errorf("ParseWithOutput([%s]), got %v, want %q without a suggestion", arg, err, ErrInvalid)
+		}
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	old := Option{Long: "old-flag", Short: 'o', Kind: KindNone, Help: "the old way", Deprecated: "use --new-flag instead"}
+	current := Option{Long: "new-flag", Short: 'n', Kind: KindNone, Help: "the new way"}
+	deprecatedOptions := []Option{old, current}
+
+	var warnings bytes.Buffer
+	conf := Config{Warnings: &warnings}
+	results, rest, err := ParseConfig(deprecatedOptions, []string{"", "--old-flag", "-n", "-o", "file"}, conf)
+
+	if err != nil {
+		t.Errorf("ParseConfig(), got error %v", err)
+	}
+	want := []Result{{Option: old}, {Option: current}, {Option: old}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("ParseConfig(), got %v, want %v", results, want)
+	}
+	if !equal(rest, []string{"file"}) {
+		t.Errorf("ParseConfig(), got rest %v, want [file]", rest)
+	}
+
+	wantWarning := "warning: --old-flag (-o) is deprecated; use --new-flag instead\n"
+	if got := warnings.String(); got != wantWarning {
+		t.Errorf("ParseConfig() warned %q, want %q", got, wantWarning)
+	}
+
+	warnings.Reset()
+	ParseConfig(deprecatedOptions, []string{"", "-n"}, conf)
+	if warnings.Len() != 0 {
+		t.Errorf("ParseConfig() warned %q without deprecated options", warnings.String())
+	}
+}
+
+func TestFormatOption(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+
+	table := []struct {
+		option Option
+		want   string
+	}{
+		{
+			Option{Long: "amend", Short: 'a', Kind: KindNone, Help: "amend a foo"},
+			fmt.Sprintf("--amend (-a)  %-50s\n", "amend a foo"),
+		},
+		{
+			Option{Long: "brief", Kind: KindRequired, Help: "be brief"},
+			fmt.Sprintf("--brief=BRIEF  %-50s\n", "be brief"),
+		},
+		{
+			Option{Short: 'c', Kind: KindOptional, Help: "use color\nwhen possible"},
+			fmt.Sprintf("-c[ARG]  %-50s\n", "use color") +
+				fmt.Sprintf("         %-50s\n", "when possible"),
+		},
+		{
+			Option{Long: "delay", Short: 'd', Kind: KindRequired, Help: "wait\n  N seconds"},
+			fmt.Sprintf("--delay=DELAY (-d DELAY)  %-50s\n", "wait") +
+				fmt.Sprintf("                          %-50s\n", "N seconds"),
+		},
+	}
+
+	for _, row := range table {
+		if got := FormatOption(row.option); got != row.want {
+			t.Errorf("FormatOption(%v), got %q, want %q", row.option.name(), got, row.want)
+		}
+	}
+
+	// In the help summary, every description lines up with the one
+	// after the longest descriptor.
+	var summary []Option
+	for _, row := range table {
+		summary = append(summary, row.option)
+	}
+	want := "\n" +
+		fmt.Sprintf("--amend (-a)              %-50s\n", "amend a foo") + "\n" +
+		fmt.Sprintf("--brief=BRIEF             %-50s\n", "be brief") + "\n" +
+		fmt.Sprintf("-c[ARG]                   %-50s\n", "use color") +
+		fmt.Sprintf("                          %-50s\n", "when possible") + "\n" +
+		fmt.Sprintf("--delay=DELAY (-d DELAY)  %-50s\n", "wait") +
+		fmt.Sprintf("                          %-50s\n", "N seconds") + "\n"
+	if got := FormatHelp(summary); got != want {
+		t.Errorf("FormatHelp(), got %q, want %q", got, want)
+	}
+}
+
+func TestHelpExample(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+
+	table := []struct {
+		option Option
+		want   string
+	}{
+		{
+			Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE", Example: "--output build/out.bin"},
+			fmt.Sprintf("--output=OUTPUT (-o OUTPUT)  %-50s\n", "write to FILE") +
+				fmt.Sprintf("                             %-50s\n", "Example: --output build/out.bin"),
+		},
+		{
+			Option{Short: 'v', Kind: KindNone, Help: "be verbose\nrepeat for more", Example: "-vvv"},
+			fmt.Sprintf("-v  %-50s\n", "be verbose") +
+				fmt.Sprintf("    %-50s\n", "repeat for more") +
+				fmt.Sprintf("    %-50s\n", "Example: -vvv"),
+		},
+		{
+			Option{Long: "quiet", Kind: KindNone, Help: "be quiet"},
+			fmt.Sprintf("--quiet  %-50s\n", "be quiet"),
+		},
+	}
+
+	for _, row := range table {
+		if got := FormatOption(row.option); got != row.want {
+			t.Errorf("FormatOption(%v), got %q, want %q", row.option.name(), got, row.want)
+		}
+	}
+}
+
+func TestShowDefaults(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	defaultOptions := []Option{
+		{Long: "color", Kind: KindOptional, Help: "colorize output", Default: "auto"},
+		{Long: "level", Kind: KindRequired, Help: "compress at LEVEL,\nfrom 1 to 9", Default: "6"},
+		{Long: "output", Kind: KindRequired, Help: "write to FILE"},
+	}
+
+	table := []struct {
+		show bool
+		want string
+	}{
+		{
+			true,
+			"\n" +
+				fmt.Sprintf("--color[=COLOR]  %-50s\n", "colorize output (default: auto)") + "\n" +
+				fmt.Sprintf("--level=LEVEL    %-50s\n", "compress at LEVEL,") +
+				fmt.Sprintf("                 %-50s\n", "from 1 to 9 (default: 6)") + "\n" +
+				fmt.Sprintf("--output=OUTPUT  %-50s\n", "write to FILE") + "\n" +
+				fmt.Sprintf("--help (-h)      %-50s\n", "Print this help message") + "\n",
+		},
+		{
+			false,
+			"\n" +
+				fmt.Sprintf("--color[=COLOR]  %-50s\n", "colorize output") + "\n" +
+				fmt.Sprintf("--level=LEVEL    %-50s\n", "compress at LEVEL,") +
+				fmt.Sprintf("                 %-50s\n", "from 1 to 9") + "\n" +
+				fmt.Sprintf("--output=OUTPUT  %-50s\n", "write to FILE") + "\n" +
+				fmt.Sprintf("--help (-h)      %-50s\n", "Print this help message") + "\n",
+		},
+	}
+
+	for _, row := range table {
+		var buf bytes.Buffer
+		ParseConfig(defaultOptions, []string{"", "--help"}, Config{Output: &buf, ShowDefaults: row.show})
+		if got := buf.String(); got != row.want {
+			t.Errorf("help with ShowDefaults %v, got %q, want %q", row.show, got, row.want)
+		}
+	}
+}
+
+func TestHelpTopics(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	proxy := Option{Long: "proxy", Kind: KindRequired, Help: "connect through URL", Group: "network"}
+	timeout := Option{Long: "timeout", Kind: KindRequired, Help: "give up after N seconds", Group: "network"}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose", Group: "output"}
+	topicOptions := []Option{proxy, verbose, timeout}
+
+	// Each summary's left column is as wide as its longest descriptor.
+	entry := func(width int, option Option) string {
+		return fmt.Sprintf("%-*s  %-50s\n\n", width, computeFlagDesc(option), option.Help)
+	}
+	help := Option{Long: "help", Short: 'h', Kind: KindOptional, Help: "Print this help message", Metavar: "TOPIC"}
+	full := "\n" + entry(26, proxy) + entry(26, verbose) + entry(26, timeout) + entry(26, help)
+
+	table := []struct {
+		args []string
+		want string
+		err  error
+	}{
+		{[]string{"", "--help"}, full, Error{Option: help, Message: ErrHelpRequested}},
+		{[]string{"", "-h"}, full, Error{Option: help, Message: ErrHelpRequested}},
+		{[]string{"", "--help=network"}, "\n" + entry(17, proxy) + entry(17, timeout), Error{Option: help, Message: ErrHelpRequested}},
+		{[]string{"", "-houtput"}, "\n" + entry(14, verbose), Error{Option: help, Message: ErrHelpRequested}},
+		{
+			[]string{"", "--help=disk"}, "",
+			Error{Option: help, Message: ErrTopic, Candidates: []string{"network", "output"}, Optarg: "disk", Token: "--help=disk", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		var buf bytes.Buffer
+		_, _, err := ParseWithOutput(topicOptions, row.args, &buf)
+		if got := buf.String(); got != row.want {
+			t.Errorf("ParseWithOutput(%q), got help %q, want %q", row.args[1:], got, row.want)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	_, _, err := ParseWithOutput(topicOptions, []string{"", "--help=disk"}, io.Discard)
+	if want := `unknown help topic: "disk" (choose from network, output)`; err == nil || err.Error() != want {
+		t.Errorf("ParseWithOutput([--help=disk]), got %v, want %q", err, want)
+	}
+
+	// Without groups, --help takes no argument.
+	_, _, err = ParseWithOutput(options, []string{"", "--help=network"}, io.Discard)
+	if !errors.Is(err, ErrTooMany) {
+		t.Errorf("ParseWithOutput([--help=network]) without groups, got %v, want %q", err, ErrTooMany)
+	}
+}
+
+func TestHelpColumns(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	columnOptions := []Option{
+		{Short: 'v', Kind: KindNone, Help: "be verbose"},
+		{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"},
+	}
+
+	table := []struct {
+		flagWidth int
+		descWidth int
+		want      string
+	}{
+		{
+			20, 0,
+			"\n" +
+				fmt.Sprintf("-v                    %-50s\n", "be verbose") + "\n" +
+				fmt.Sprintf("--output=OUTPUT (-o OUTPUT)\n                      %-50s\n", "write to FILE") + "\n" +
+				fmt.Sprintf("--help (-h)           %-50s\n", "Print this help message") + "\n",
+		},
+		{
+			30, 15,
+			"\n" +
+				fmt.Sprintf("-v                              %-15s\n", "be verbose") + "\n" +
+				fmt.Sprintf("--output=OUTPUT (-o OUTPUT)     %-15s\n", "write to FILE") + "\n" +
+				fmt.Sprintf("--help (-h)                     %-15s\n", "Print this help message") + "\n",
+		},
+		{
+			0, 15,
+			"\n" +
+				fmt.Sprintf("-v                           %-15s\n", "be verbose") + "\n" +
+				fmt.Sprintf("--output=OUTPUT (-o OUTPUT)  %-15s\n", "write to FILE") + "\n" +
+				fmt.Sprintf("--help (-h)                  %-15s\n", "Print this help message") + "\n",
+		},
+	}
+
+	for _, row := range table {
+		var buf bytes.Buffer
+		conf := Config{Output: &buf, HelpFlagWidth: row.flagWidth, HelpDescWidth: row.descWidth}
+		ParseConfig(columnOptions, []string{"", "--help"}, conf)
+		if got := buf.String(); got != row.want {
+			t.Errorf("help with widths %d and %d, got %q, want %q", row.flagWidth, row.descWidth, got, row.want)
+		}
+	}
+}
+
+func TestHelpWrap(t *testing.T) {
+	wrapOptions := []Option{
+		{Long: "amend", Short: 'a', Kind: KindNone, Help: "amend a foo, then write the result back to the same file it came from"},
+		{Short: 's', Kind: KindNone, Help: "quick switch\n    configuration"},
+	}
+
+	// "--amend (-a)" puts the description at column 14, leaving
+	// 46 columns for it.
+	want := "\n" +
+		fmt.Sprintf("--amend (-a)  %-50s\n", "amend a foo, then write the result back to the") +
+		fmt.Sprintf("              %-50s\n", "same file it came from") +
+		"\n" +
+		fmt.Sprintf("-s            %-50s\n", "quick switch") +
+		fmt.Sprintf("              %-50s\n", "configuration") +
+		"\n"
+	if got := FormatHelpWidth(wrapOptions, 60); got != want {
+		t.Errorf("FormatHelpWidth(60), got %q, want %q", got, want)
+	}
+
+	// Too narrow a terminal still leaves room for a few words.
+	want = "\n" +
+		fmt.Sprintf("--amend (-a)  %-50s\n", "amend a foo, then") +
+		fmt.Sprintf("              %-50s\n", "write the result") +
+		fmt.Sprintf("              %-50s\n", "back to the same") +
+		fmt.Sprintf("              %-50s\n", "file it came from") +
+		"\n"
+	if got := FormatHelpWidth(wrapOptions[:1], 10); got != want {
+		t.Errorf("FormatHelpWidth(10), got %q, want %q", got, want)
+	}
+
+	// COLUMNS sets the width, falling back to 80 columns.
+	t.Setenv("COLUMNS", "60")
+	if got, want := FormatHelp(wrapOptions), FormatHelpWidth(wrapOptions, 60); got != want {
+		t.Errorf("FormatHelp() with COLUMNS=60, got %q, want %q", got, want)
+	}
+	t.Setenv("COLUMNS", "")
+	if got, want := FormatHelp(wrapOptions), FormatHelpWidth(wrapOptions, 80); got != want {
+		t.Errorf("FormatHelp() without COLUMNS, got %q, want %q", got, want)
+	}
+
+	// Help written elsewhere than to a terminal ignores COLUMNS.
+	var narrow, wide bytes.Buffer
+	t.Setenv("COLUMNS", "60")
+	ParseWithOutput(wrapOptions, []string{"", "--help"}, &narrow)
+	t.Setenv("COLUMNS", "")
+	ParseWithOutput(wrapOptions, []string{"", "--help"}, &wide)
+	if narrow.String() != wide.String() {
+		t.Errorf("help output to a buffer with COLUMNS=60, got %q, want %q", narrow.String(), wide.String())
+	}
+}
+
+func TestNoAttached(t *testing.T) {
+	password := Option{Long: "password", Short: 'p', Kind: KindRequired, Help: "log in with SECRET", NoAttached: true}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	secretOptions := []Option{password, verbose}
+
+	table := []struct {
+		args    []string
+		results []Result
+		rest    []string
+		err     error
+	}{
+		{
+			[]string{"", "--password", "secret", "file"},
+			[]Result{{Option: password, Optarg: "secret", HasArg: true}},
+			[]string{"file"},
+			nil,
+		},
+		{
+			[]string{"", "-vp", "secret"},
+			[]Result{{Option: verbose}, {Option: password, Optarg: "secret", HasArg: true}},
+			[]string{},
+			nil,
+		},
+		{
+			[]string{"", "--password=secret"},
+			nil,
+			[]string{},
+			Error{Option: password, Message: ErrAttached, Token: "--password=secret", Index: 1},
+		},
+		{
+			[]string{"", "-psecret"},
+			nil,
+			[]string{},
+			Error{Option: password, Message: ErrAttached, Token: "-psecret", Index: 1},
+		},
+		{
+			[]string{"", "--pass="},
+			nil,
+			[]string{},
+			Error{Option: password, Message: ErrAttached, Token: "--pass=", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseWithOutput(secretOptions, row.args, nil)
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseWithOutput(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+}
+
+func TestWarnLateOptions(t *testing.T) {
+	lateOptions := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+		{Long: "extract", Short: 'x', Kind: KindNone, Help: "extract files"},
+	}
+	warning := "warning: -x follows the non-option argument file and was not parsed; " +
+		"options must come first, as argument permutation is off\n"
+
+	table := []struct {
+		args    []string
+		config  Config
+		warning string
+	}{
+		{[]string{"", "-v", "file", "-x"}, Config{WarnLateOptions: true}, warning},
+		{[]string{"", "-v", "file", "plain", "-x", "--extract"}, Config{WarnLateOptions: true}, warning},
+		{[]string{"", "-v", "file", "plain"}, Config{WarnLateOptions: true}, ""},
+		{[]string{"", "-v", "file", "-"}, Config{WarnLateOptions: true}, ""},
+		{[]string{"", "-v", "file", "--", "-x"}, Config{WarnLateOptions: true}, ""},
+		{[]string{"", "-v", "--", "file", "-x"}, Config{WarnLateOptions: true}, ""},
+		{[]string{"", "-v", "file", "-x"}, Config{WarnLateOptions: true, Permute: true}, ""},
+		{[]string{"", "-v", "file", "-x"}, Config{}, ""},
+	}
+
+	for _, row := range table {
+		var warnings bytes.Buffer
+		row.config.Warnings = &warnings
+		ParseConfig(lateOptions, row.args, row.config)
+		if got := warnings.String(); got != row.warning {
+			t.Errorf("ParseConfig(%q) with %+v, warned %q, want %q", row.args[1:], row.config, got, row.warning)
+		}
+	}
+
+	// POSIXLY_CORRECT turns permutation back off.
+	t.Setenv("POSIXLY_CORRECT", "1")
+	var warnings bytes.Buffer
+	ParseConfig(lateOptions, []string{"", "-v", "file", "-x"}, Config{Warnings: &warnings, Permute: true, WarnLateOptions: true})
+	if got := warnings.String(); got != warning {
+		t.Errorf("ParseConfig() with POSIXLY_CORRECT set, warned %q, want %q", got, warning)
+	}
+}
+
+func TestPlusNegation(t *testing.T) {
+	trace := Option{Short: 'x', Kind: KindNone, Help: "trace commands"}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	plusOptions := []Option{trace, verbose, output}
+
+	table := []struct {
+		args    []string
+		results []Result
+		rest    []string
+		err     error
+	}{
+		{[]string{"", "+x"}, []Result{{Option: trace, Negated: true}}, []string{}, nil},
+		{[]string{"", "-x"}, []Result{{Option: trace}}, []string{}, nil},
+		{
+			[]string{"", "+xv", "-x", "file"},
+			[]Result{{Option: trace, Negated: true}, {Option: verbose, Negated: true}, {Option: trace}},
+			[]string{"file"},
+			nil,
+		},
+		{[]string{"", "+", "-x"}, nil, []string{"+", "-x"}, nil},
+		{
+			[]string{"", "+xo", "file"},
+			[]Result{{Option: trace, Negated: true}},
+			[]string{"+xo", "file"},
+			Error{Option: output, Message: ErrNotNegatable, Token: "+xo", Index: 1},
+		},
+		{
+			[]string{"", "+z"},
+			nil,
+			[]string{"+z"},
+			Error{Option: Option{Short: 'z'}, Message: ErrInvalid, Token: "+z", Index: 1},
+		},
+	}
+
+	for _, row := range table {
+		results, rest, err := ParseConfig(plusOptions, row.args, Config{Output: io.Discard, PlusNegation: true})
+		if !reflect.DeepEqual(results, row.results) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], results, row.results)
+		}
+		if !equal(rest, row.rest) {
+			t.Errorf("ParseConfig(%q), got rest %q, want %q", row.args[1:], rest, row.rest)
+		}
+		if !reflect.DeepEqual(err, row.err) {
+			t.Errorf("ParseConfig(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+	}
+
+	// Without PlusNegation, "+x" is a non-option argument.
+	results, rest, err := ParseWithOutput(plusOptions, []string{"", "+x", "-x"}, nil)
+	if err != nil || len(results) != 0 || !equal(rest, []string{"+x", "-x"}) {
+		t.Errorf("ParseWithOutput([+x -x]), got %v, %q, and %v, want no results and rest [+x -x]", results, rest, err)
+	}
+
+	// Like ParseAll, ValidateConfig moves past an option that can't
+	// be negated.
+	errs := ValidateConfig(plusOptions, []string{"", "+ox", "+o"}, Config{PlusNegation: true})
+	if len(errs) != 2 || !errors.Is(errs[0], ErrNotNegatable) || !errors.Is(errs[1], ErrNotNegatable) {
+		t.Errorf("ValidateConfig([+ox +o]), got %v, want %q twice", errs, ErrNotNegatable)
+	}
+
+	// Unknown options pass through with their "+".
+	_, rest, _ = ParseConfig(plusOptions, []string{"", "+xz"}, Config{Output: io.Discard, PlusNegation: true, IgnoreUnknown: true})
+	if !equal(rest, []string{"+z"}) {
+		t.Errorf("ParseConfig([+xz]) with IgnoreUnknown, got rest %q, want [+z]", rest)
+	}
+}
+
+func TestHelpFunc(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	calls := 0
+	jobs := Option{Long: "jobs", Short: 'j', Kind: KindRequired, HelpFunc: func() string {
+		calls++
+		return "run N jobs at once\n(default: 4 on this machine)"
+	}}
+	quiet := Option{Long: "quiet", Kind: KindNone, Help: "be quiet", HelpFunc: func() string {
+		calls++
+		return "never shown"
+	}}
+	funcOptions := []Option{jobs, quiet}
+
+	results, _, err := ParseWithOutput(funcOptions, []string{"", "-j", "2", "--quiet"}, nil)
+	if err != nil {
+		t.Fatalf("ParseWithOutput() with a HelpFunc, got error %v", err)
+	}
+	if err := MatchResults(results, "--jobs=2", "--quiet"); err != nil {
+		t.Errorf("ParseWithOutput() with a HelpFunc, %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("ParseWithOutput() without --help, HelpFunc called %d times, want 0", calls)
+	}
+
+	var buf bytes.Buffer
+	ParseWithOutput(funcOptions, []string{"", "--help"}, &buf)
+	want := "\n" +
+		fmt.Sprintf("--jobs=JOBS (-j JOBS)  %-50s\n", "run N jobs at once") +
+		fmt.Sprintf("                       %-50s\n", "(default: 4 on this machine)") + "\n" +
+		fmt.Sprintf("--quiet                %-50s\n", "be quiet") + "\n" +
+		fmt.Sprintf("--help (-h)            %-50s\n", "Print this help message") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("ParseWithOutput(--help), got %q, want %q", got, want)
+	}
+	if calls != 1 {
+		t.Errorf("ParseWithOutput(--help), HelpFunc called %d times, want 1", calls)
+	}
+}
+
+func TestLazyOptional(t *testing.T) {
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	optimize := Option{Long: "optimize", Short: 'O', Kind: KindOptional, Help: "optimize at LEVEL", Default: "1"}
+	dry := Option{Short: 'n', Kind: KindNone, Help: "dry run"}
+	extract := Option{Short: 'x', Kind: KindNone, Help: "extract"}
+	output := Option{Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	lazyOptions := []Option{verbose, optimize, dry, extract, output}
+
+	table := []struct {
+		args   []string
+		greedy []string
+		lazy   []string
+	}{
+		{[]string{"", "-O2"}, []string{"--optimize=2"}, []string{"--optimize=2"}},
+		{[]string{"", "-On"}, []string{"--optimize=n"}, []string{"--optimize", "-n"}},
+		{[]string{"", "-Onx"}, []string{"--optimize=nx"}, []string{"--optimize", "-n", "-x"}},
+		{[]string{"", "-vOnofile"}, []string{"--verbose", "--optimize=nofile"}, []string{"--verbose", "--optimize", "-n", "-o=file"}},
+		{[]string{"", "-Onz"}, []string{"--optimize=nz"}, []string{"--optimize=nz"}},
+		{[]string{"", "-O=n"}, []string{"--optimize=n"}, []string{"--optimize=n"}},
+		{[]string{"", "-O", "-n"}, []string{"--optimize", "-n"}, []string{"--optimize", "-n"}},
+	}
+
+	for _, row := range table {
+		for _, lazy := range []bool{false, true} {
+			want := row.greedy
+			if lazy {
+				want = row.lazy
+			}
+			results, rest, err := ParseConfig(lazyOptions, row.args, Config{Output: io.Discard, LazyOptional: lazy})
+			if err != nil {
+				t.Errorf("ParseConfig(%q) with LazyOptional %v, got error %v", row.args[1:], lazy, err)
+				continue
+			}
+			if err := MatchResults(results, want...); err != nil {
+				t.Errorf("ParseConfig(%q) with LazyOptional %v, %v", row.args[1:], lazy, err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("ParseConfig(%q) with LazyOptional %v, got rest %q, want none", row.args[1:], lazy, rest)
+			}
+		}
+	}
+
+	// Without an argument, the option falls back to its Default.
+	results, _, _ := ParseConfig(lazyOptions, []string{"", "-On"}, Config{Output: io.Discard, LazyOptional: true})
+	if want := (Result{Option: optimize, Optarg: "1"}); len(results) == 0 || !reflect.DeepEqual(results[0], want) {
+		t.Errorf("ParseConfig([-On]) with LazyOptional, got %v, want %v first", results, want)
+	}
+}
+
+func TestEmptyArgs(t *testing.T) {
+	for _, args := range [][]string{nil, {}, {"prog"}} {
+		results, rest, err := ParseWithOutput(options, args, nil)
+		if err != nil || len(results) != 0 || len(rest) != 0 {
+			t.Errorf("ParseWithOutput(%q), got %v, %q, and %v, want nothing", args, results, rest, err)
+		}
+
+		results, rest, errs := ParseAll(options, args)
+		if errs != nil || len(results) != 0 || len(rest) != 0 {
+			t.Errorf("ParseAll(%q), got %v, %q, and %v, want nothing", args, results, rest, errs)
+		}
+
+		var p Parser
+		results, rest, err = p.Parse(args)
+		if err != nil || len(results) != 0 || len(rest) != 0 || p.Terminated() {
+			t.Errorf("Parser.Parse(%q), got %v, %q, and %v, want nothing", args, results, rest, err)
+		}
+
+		// Options are still checked, as are missing ones.
+		_, _, err = ParseWithOutput([]Option{{Long: "verbose"}}, args, nil)
+		if !errors.Is(err, ErrHelpMissing) {
+			t.Errorf("ParseWithOutput(%q) without Help, got %v, want %q", args, err, ErrHelpMissing)
+		}
+		required := Option{Long: "output", Kind: KindRequired, Help: "write to FILE", Required: true}
+		_, _, err = ParseWithOutput([]Option{required}, args, nil)
+		if !errors.Is(err, ErrRequired) {
+			t.Errorf("ParseWithOutput(%q) with a Required option, got %v, want %q", args, err, ErrRequired)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// COLOR is normalized too, when --color isn't given.
+	t.Setenv("COLOR", " Never")
+	expandHome := func(path string) string {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			dir, err := os.UserHomeDir()
+			if err == nil {
+				return dir + path[1:]
+			}
+		}
+		return path
+	}
+	lower := func(s string) string {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+
+	color := Option{Long: "color", Kind: KindOptional, Help: "colorize output", Choices: []string{"auto", "always", "never"}, Normalize: lower, Env: "COLOR"}
+	config := Option{Long: "config", Short: 'c', Kind: KindRequired, Help: "read FILE", Normalize: expandHome}
+	copyTo := Option{Long: "copy", Kind: KindRequired, Help: "copy FROM to TO", NArgs: 2, Normalize: expandHome}
+	normalOptions := []Option{color, config, copyTo}
+
+	table := []struct {
+		args    []string
+		results []string
+		err     error
+	}{
+		{[]string{"", "--color=AUTO"}, []string{"--color=auto"}, nil},
+		{[]string{"", "--color= Always "}, []string{"--color=always"}, nil},
+		{[]string{"", "--color"}, []string{"--color"}, nil},
+		{[]string{"", "--color=Sometimes"}, nil, ErrChoice},
+		{[]string{"", "-c~/app.conf"}, []string{"--config=" + home + "/app.conf", "--color=never"}, nil},
+		{[]string{"", "--config", "~"}, []string{"--config=" + home, "--color=never"}, nil},
+		{[]string{"", "--config", "/etc/~app"}, []string{"--config=/etc/~app", "--color=never"}, nil},
+		{[]string{"", "--copy", "~/a", "b"}, []string{"--copy=" + home + "/a b", "--color=never"}, nil},
+	}
+
+	for _, row := range table {
+		results, _, err := ParseWithOutput(normalOptions, row.args, nil)
+		if !errors.Is(err, row.err) {
+			t.Errorf("ParseWithOutput(%q), got %v, want %v", row.args[1:], err, row.err)
+		}
+		if row.err != nil {
+			continue
+		}
+		if err := MatchResults(results, row.results...); err != nil {
+			t.Errorf("ParseWithOutput(%q), %v", row.args[1:], err)
+		}
+	}
+
+	results, _, _ := ParseWithOutput(normalOptions, []string{"", "--copy", "~/a", "~/b"}, nil)
+	if len(results) == 0 || results[0].Optarg != home+"/a" {
+		t.Errorf("ParseWithOutput([--copy ~/a ~/b]), got %v, want Optarg %q", results, home+"/a")
+	}
+
+	// A normalizer that empties the argument doesn't escape validation.
+	erase := Option{Long: "erase", Kind: KindRequired, Help: "erase MODE", Choices: []string{"a"}, Normalize: func(string) string { return "" }}
+	_, _, err := ParseWithOutput([]Option{erase}, []string{"", "--erase=zzz"}, nil)
+	if e, ok := err.(Error); !ok || e.Message != ErrChoice || e.Optarg != "" {
+		t.Errorf("ParseWithOutput([--erase=zzz]) with an emptying Normalize, got %v, want %q for \"\"", err, ErrChoice)
+	}
+}
@@ -0,0 +1,53 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import "iter"
+
+// All returns an iterator over the options parsed from args, for use
+// with range-over-func:
+//
+//	for result, err := range All(options, os.Args) {
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		...
+//	}
+//
+// Options are parsed lazily, one per iteration, so breaking out of the
+// loop stops parsing early. The iteration ends when the options run
+// out, as with Parse, or right after yielding an error.
+//
+// In this mode, --help is yielded like any other option, and printing
+// the help summary is up to the caller. Only options appearing in args
+// are yielded, and the checks that Parse makes once all options are
+// known, such as for Required options, are skipped.
+func All(options []Option, args []string) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		var p Parser
+		if err := p.Init(options); err != nil {
+			yield(Result{}, err)
+			return
+		}
+
+		parser := &p.parser
+		parser.args = args
+		for {
+			result, err := parser.next()
+			if err != nil {
+				yield(Result{}, err)
+				return
+			}
+			if result == nil {
+				return
+			}
+			if err := result.accept(p.patterns[result.Pattern]); err != nil {
+				yield(*result, err)
+				return
+			}
+			if !yield(*result, nil) {
+				return
+			}
+		}
+	}
+}
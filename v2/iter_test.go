@@ -0,0 +1,57 @@
+package v2
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	var longs []string
+	for result, err := range All(options, []string{"", "-ab", "--delay", "10", "-h", "foo", "-e"}) {
+		if err != nil {
+			t.Fatalf("All(), got error %v", err)
+		}
+		longs = append(longs, result.Long)
+	}
+	if want := []string{"amend", "brief", "delay", "help"}; !equal(longs, want) {
+		t.Errorf("All(), got %v, want %v", longs, want)
+	}
+
+	// Breaking out of the loop stops parsing before the error.
+	longs = nil
+	for result, err := range All(options, []string{"", "-a", "-b", "-x"}) {
+		if err != nil {
+			t.Fatalf("All(), got error %v after breaking", err)
+		}
+		longs = append(longs, result.Long)
+		if result.Long == "brief" {
+			break
+		}
+	}
+	if want := []string{"amend", "brief"}; !equal(longs, want) {
+		t.Errorf("All() with break, got %v, want %v", longs, want)
+	}
+
+	// Errors end the iteration.
+	var errs []error
+	longs = nil
+	for result, err := range All(options, []string{"", "-a", "-x", "-b"}) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		longs = append(longs, result.Long)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrInvalid) {
+		t.Errorf("All(), got errors %v, want one %q", errs, ErrInvalid)
+	}
+	if want := []string{"amend"}; !equal(longs, want) {
+		t.Errorf("All() with error, got %v, want %v", longs, want)
+	}
+
+	for _, err := range All([]Option{{Long: "nohelp"}}, []string{""}) {
+		if !errors.Is(err, ErrHelpMissing) {
+			t.Errorf("All() with invalid options, got %v, want %q", err, ErrHelpMissing)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonResult is the JSON form of a Result.
+type jsonResult struct {
+	Long    string   `json:"long"`
+	Short   string   `json:"short"`
+	Kind    string   `json:"kind"`
+	Optarg  string   `json:"optarg"`
+	Negated bool     `json:"negated,omitempty"`
+	Optargs []string `json:"optargs,omitempty"`
+}
+
+// MarshalJSON encodes the result for logging and debugging, as in:
+//
+//	{"long":"output","short":"o","kind":"required","optarg":"file"}
+//
+// The short form is a string holding the character, or empty when
+// there is none, as is the long form. The kind is one of "none",
+// "required", or "optional". The "negated" and "optargs" fields only
+// appear when set. The rest of the option, such as its Help, is left
+// out.
+func (r Result) MarshalJSON() ([]byte, error) {
+	kind, ok := kindNames[r.Kind]
+	if !ok {
+		return nil, fmt.Errorf("invalid Kind %d for %s", r.Kind, r.name())
+	}
+
+	short := ""
+	if r.Short != 0 {
+		short = string(r.Short)
+	}
+	return json.Marshal(jsonResult{
+		Long:    r.Long,
+		Short:   short,
+		Kind:    kind,
+		Optarg:  r.Optarg,
+		Negated: r.Negated,
+		Optargs: r.Optargs,
+	})
+}
@@ -0,0 +1,48 @@
+package v2
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	output := Option{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"}
+	color := Option{Long: "color", Kind: KindOptional, Help: "colorize output", Negatable: true}
+	pi := Option{Short: 'π', Kind: KindNone, Help: "3.14", Handler: func(string) error { return nil }}
+	point := Option{Long: "point", Kind: KindRequired, Help: "plot X and Y", NArgs: 2}
+
+	table := []struct {
+		results []Result
+		want    string
+	}{
+		{[]Result{}, `[]`},
+		{
+			[]Result{{Option: output, Optarg: "file", HasArg: true}},
+			`[{"long":"output","short":"o","kind":"required","optarg":"file"}]`,
+		},
+		{
+			[]Result{{Option: color, Negated: true}, {Option: pi}},
+			`[{"long":"color","short":"","kind":"optional","optarg":"","negated":true},` +
+				`{"long":"","short":"π","kind":"none","optarg":""}]`,
+		},
+		{
+			[]Result{{Option: point, Optarg: "1", HasArg: true, Optargs: []string{"1", "2"}}},
+			`[{"long":"point","short":"","kind":"required","optarg":"1","optargs":["1","2"]}]`,
+		},
+	}
+
+	for _, row := range table {
+		got, err := json.Marshal(row.results)
+		if err != nil {
+			t.Errorf("json.Marshal(%v), got error %v", row.results, err)
+			continue
+		}
+		if string(got) != row.want {
+			t.Errorf("json.Marshal(%v), got %s, want %s", row.results, got, row.want)
+		}
+	}
+
+	if _, err := json.Marshal(Result{Option: Option{Long: "bad", Kind: 7}}); err == nil {
+		t.Errorf("json.Marshal() with an invalid Kind, got no error")
+	}
+}
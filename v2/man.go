@@ -0,0 +1,76 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// GenManOptions returns the OPTIONS section of a man page documenting
+// the given options, as well as --help, in troff format. Each option
+// gets a .TP entry, with its names and argument placeholder on the tag
+// line, followed by its Help text. Hidden options are left out. The
+// result is meant to be pasted into a page using the man macros.
+func GenManOptions(options []Option) string {
+	options = append(options[:len(options):len(options)], helpOption)
+
+	var b strings.Builder
+	b.WriteString(".SH OPTIONS\n")
+	for _, option := range options {
+		if option.Hidden {
+			continue
+		}
+		b.WriteString(".TP\n")
+		b.WriteString(manTag(option) + "\n")
+
+		scanner := bufio.NewScanner(strings.NewReader(option.helpText()))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				b.WriteString(manEscape(line) + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// manTag renders the names of an option for the tag line of its .TP
+// entry, as in "\fB\-o\fR \fIFILE\fR, \fB\-\-output\fR=\fIFILE\fR",
+// with the names in bold and the placeholders in italics.
+func manTag(option Option) string {
+	var longArg, shortArg string
+	meta := `\fI` + manEscape(metavar(option)) + `\fR`
+	switch option.Kind {
+	case KindRequired:
+		longArg = "=" + meta
+		shortArg = " " + meta
+	case KindOptional:
+		longArg = "[=" + meta + "]"
+		shortArg = "[" + meta + "]"
+	}
+
+	var names []string
+	if option.Short != 0 {
+		names = append(names, fmt.Sprintf(`\fB%s\fR%s`, manEscape(fmt.Sprintf("-%c", option.Short)), shortArg))
+	}
+	for _, name := range option.longNames() {
+		names = append(names, `\fB`+manEscape("--"+name)+`\fR`+longArg)
+		if option.Negatable {
+			names = append(names, `\fB`+manEscape("--no-"+name)+`\fR`)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// manEscape escapes text for troff, so that backslashes and dashes are
+// printed as is, and a leading period or quote isn't taken as a
+// request.
+func manEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
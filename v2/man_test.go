@@ -0,0 +1,31 @@
+package v2
+
+import "testing"
+
+func TestGenManOptions(t *testing.T) {
+	manOptions := []Option{
+		{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE", Metavar: "FILE"},
+		{Long: "color", Kind: KindOptional, Help: "colorize output\n    .when it's a terminal", Negatable: true},
+		{Short: 'v', Kind: KindNone, Help: `escape \ and -`},
+		{Long: "debug", Kind: KindNone, Help: "internal", Hidden: true},
+	}
+
+	want := ".SH OPTIONS\n" +
+		".TP\n" +
+		`\fB\-o\fR \fIFILE\fR, \fB\-\-output\fR=\fIFILE\fR` + "\n" +
+		"write to FILE\n" +
+		".TP\n" +
+		`\fB\-\-color\fR[=\fICOLOR\fR], \fB\-\-no\-color\fR` + "\n" +
+		"colorize output\n" +
+		`\&.when it's a terminal` + "\n" +
+		".TP\n" +
+		`\fB\-v\fR` + "\n" +
+		`escape \e and \-` + "\n" +
+		".TP\n" +
+		`\fB\-h\fR, \fB\-\-help\fR` + "\n" +
+		"Print this help message\n"
+
+	if got := GenManOptions(manOptions); got != want {
+		t.Errorf("GenManOptions(), got:\n%s\nwant:\n%s", got, want)
+	}
+}
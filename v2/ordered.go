@@ -0,0 +1,41 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+// Token is an element of the command line as returned by ParseOrdered:
+// either an option, or a non-option argument.
+type Token struct {
+	// Option is the parsed option, or nil for a non-option
+	// argument.
+	Option *Result
+
+	// Arg is the non-option argument, when Option is nil.
+	Arg string
+}
+
+// ParseOrdered is like ParseConfig with Permute set, but instead of
+// the results and the remaining arguments, it returns them merged into
+// a single list, in the order they were given. This suits programs for
+// which the position of options among the other arguments matters,
+// such as a linker given "-L a foo -L b bar", where each -L applies to
+// the arguments after it.
+//
+// The arguments after a "--" are listed like any other non-option
+// argument, while the "--" itself is not. Options taken from the
+// environment or a ConfigFile are not part of the command line, so
+// they're left out. On error, the tokens parsed so far are returned.
+func ParseOrdered(options []Option, args []string, config Config) ([]Token, error) {
+	config.Permute = true
+	p := Parser{Config: config}
+	if err := p.Init(options); err != nil {
+		return []Token{}, err
+	}
+
+	tokens := []Token{}
+	p.tokens = &tokens
+	_, _, errs := p.parse(args, false)
+	if len(errs) > 0 {
+		return tokens, errs[0]
+	}
+	return tokens, nil
+}
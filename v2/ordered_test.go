@@ -0,0 +1,55 @@
+package v2
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrdered(t *testing.T) {
+	libdir := Option{Short: 'L', Kind: KindRequired, Help: "search DIR for libraries"}
+	verbose := Option{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"}
+	orderedOptions := []Option{libdir, verbose}
+
+	option := func(option Option, optarg string) Token {
+		return Token{Option: &Result{Option: option, Optarg: optarg, HasArg: option.Kind == KindRequired}}
+	}
+
+	table := []struct {
+		args []string
+		want []Token
+	}{
+		{[]string{""}, []Token{}},
+		{
+			[]string{"", "-L", "a", "foo", "-L", "b", "bar"},
+			[]Token{option(libdir, "a"), {Arg: "foo"}, option(libdir, "b"), {Arg: "bar"}},
+		},
+		{
+			[]string{"", "foo", "bar", "-vLa", "baz"},
+			[]Token{{Arg: "foo"}, {Arg: "bar"}, option(verbose, ""), option(libdir, "a"), {Arg: "baz"}},
+		},
+		{
+			[]string{"", "foo", "-v", "--", "-L", "bar"},
+			[]Token{{Arg: "foo"}, option(verbose, ""), {Arg: "-L"}, {Arg: "bar"}},
+		},
+	}
+
+	for _, row := range table {
+		got, err := ParseOrdered(orderedOptions, row.args, Config{})
+		if err != nil {
+			t.Errorf("ParseOrdered(%q), got error %v", row.args[1:], err)
+		}
+		if !reflect.DeepEqual(got, row.want) {
+			t.Errorf("ParseOrdered(%q), got %v, want %v", row.args[1:], got, row.want)
+		}
+	}
+
+	got, err := ParseOrdered(orderedOptions, []string{"", "foo", "-v", "-x", "bar"}, Config{Output: io.Discard})
+	if want := []Token{{Arg: "foo"}, option(verbose, "")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() with an error, got %v, want %v", got, want)
+	}
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseOrdered() with an error, got %v, want %q", err, ErrInvalid)
+	}
+}
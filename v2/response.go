@@ -0,0 +1,130 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExpandResponseFiles returns a copy of args in which every argument
+// of the form "@file", other than args[0], is replaced by the
+// arguments read from file. This works around limits on the length of
+// a command line, and is meant to be called before Parse.
+//
+// Arguments in a response file are separated by whitespace, including
+// newlines. An argument containing whitespace may be enclosed in
+// single or double quotes. Outside of single quotes, a backslash
+// escapes a quote after it, as does one before whitespace outside of
+// any quotes. Any other backslash is kept as is, so that Windows paths
+// such as "C:\Users\me" come through unchanged. Response files may
+// refer to other response files, but not to themselves, directly or
+// indirectly.
+func ExpandResponseFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+	rest, err := expandResponseFiles(args[1:], nil)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{args[0]}, rest...), nil
+}
+
+// expandResponseFiles expands the arguments found in the response
+// files named by the "@file" arguments in args. The names of the files
+// currently being expanded are kept in open, to detect cycles.
+func expandResponseFiles(args []string, open []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if len(arg) < 2 || arg[0] != '@' {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		name := arg[1:]
+		if contains(open, name) {
+			return nil, fmt.Errorf("response file %s includes itself", name)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading response file: %w", err)
+		}
+
+		fileArgs, err := splitArgs(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("response file %s: %w", name, err)
+		}
+
+		fileArgs, err = expandResponseFiles(fileArgs, append(open, name))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, fileArgs...)
+	}
+	return expanded, nil
+}
+
+// splitArgs splits s into arguments at whitespace, honoring single
+// quotes, double quotes and backslash escapes much as a shell would,
+// except that a backslash only escapes quotes and whitespace.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'' && c == '\'':
+			quote = 0
+		case quote == '\'':
+			arg.WriteRune(c)
+		case c == '\\' && i+1 < len(runes) && escapable(runes[i+1], quote):
+			i++
+			arg.WriteRune(runes[i])
+			inArg = true
+		case quote == '"' && c == '"':
+			quote = 0
+		case quote == '"':
+			arg.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// escapable reports whether a backslash escapes c, given the quote
+// that c appears within, if any: quotes can always be escaped, and
+// whitespace only outside of quotes.
+func escapable(c, quote rune) bool {
+	switch c {
+	case '"', '\'':
+		return true
+	case ' ', '\t', '\n', '\r':
+		return quote == 0
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package v2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExpandResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	simple := writeFile(t, dir, "simple.txt", "-a --delay 10\n-e\n")
+	quoted := writeFile(t, dir, "quoted.txt", `--color "light blue" 'it''s' a\ b "say \"hi\"" ''`)
+	windows := writeFile(t, dir, "windows.txt", `"C:\Users\me\out.txt" C:\tmp\a\ b.txt \\server\share`)
+	nested := writeFile(t, dir, "nested.txt", "-b @"+simple+" last")
+	loop := filepath.Join(dir, "loop.txt")
+	writeFile(t, dir, "loop.txt", "@"+loop)
+
+	table := []struct {
+		args []string
+		want []string
+	}{
+		{
+			[]string{"prog", "@" + simple, "file"},
+			[]string{"prog", "-a", "--delay", "10", "-e", "file"},
+		},
+		{
+			[]string{"prog", "@" + quoted},
+			[]string{"prog", "--color", "light blue", "its", "a b", `say "hi"`, ""},
+		},
+		{
+			[]string{"prog", "@" + windows},
+			[]string{"prog", `C:\Users\me\out.txt`, `C:\tmp\a b.txt`, `\\server\share`},
+		},
+		{
+			[]string{"prog", "@" + nested},
+			[]string{"prog", "-b", "-a", "--delay", "10", "-e", "last"},
+		},
+		{
+			// args[0] and a bare "@" are left alone
+			[]string{"@prog", "@"},
+			[]string{"@prog", "@"},
+		},
+	}
+
+	for _, row := range table {
+		got, err := ExpandResponseFiles(row.args)
+		if err != nil {
+			t.Errorf("ExpandResponseFiles(%q), got error %v", row.args, err)
+		} else if !equal(got, row.want) {
+			t.Errorf("ExpandResponseFiles(%q), got %q, want %q", row.args, got, row.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"prog", "@" + loop},
+		{"prog", "@" + filepath.Join(dir, "absent.txt")},
+		{"prog", "@" + writeFile(t, dir, "unterminated.txt", `"oops`)},
+	} {
+		if _, err := ExpandResponseFiles(args); err == nil {
+			t.Errorf("ExpandResponseFiles(%q), expected an error", args)
+		}
+	}
+}
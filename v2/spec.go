@@ -0,0 +1,94 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseSpec builds options from a textual spec, for programs that
+// define them in data, such as plugins. Each line defines an option
+// with four fields separated by commas: the long name, the short name,
+// the kind, and the help text, as in:
+//
+//	verbose,v,none,Be verbose
+//	output,o,required,Write to FILE
+//	color,,optional,Colorize output, when possible
+//
+// Either name may be left empty, but not both, and the short name must
+// be a single character. The kind is one of "none", "required", or
+// "optional", as returned by Kind.String. Since the help text comes
+// last, it may hold commas of its own, but it may not be empty. Blank
+// lines, and lines starting with "#", are skipped, and spaces around
+// the fields are trimmed.
+//
+// The first malformed line fails with an error giving its number, and
+// a line too long to read fails as well, rather than cutting the spec
+// short. The options are otherwise checked as usual when parsing.
+func ParseSpec(spec string) ([]Option, error) {
+	var options []Option
+	scanner := bufio.NewScanner(strings.NewReader(spec))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		option, err := specOption(line)
+		if err != nil {
+			return nil, fmt.Errorf("optparse: spec line %d: %w", n, err)
+		}
+		options = append(options, option)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("optparse: spec: %w", err)
+	}
+	return options, nil
+}
+
+// specOption builds an option from a line of a spec.
+func specOption(line string) (Option, error) {
+	fields := strings.SplitN(line, ",", 4)
+	if len(fields) < 4 {
+		return Option{}, fmt.Errorf("got %d fields, want 4", len(fields))
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	option := Option{Long: fields[0], Help: fields[3]}
+	if fields[1] != "" {
+		short, size := utf8.DecodeRuneInString(fields[1])
+		if size != len(fields[1]) {
+			return Option{}, fmt.Errorf("short name %q is not a single character", fields[1])
+		}
+		option.Short = short
+	}
+	if option.Long == "" && option.Short == 0 {
+		return Option{}, ErrUnnamed
+	}
+
+	kind, ok := kindByName(fields[2])
+	if !ok {
+		return Option{}, fmt.Errorf("unknown kind %q", fields[2])
+	}
+	option.Kind = kind
+
+	if option.Help == "" {
+		return Option{}, ErrHelpMissing
+	}
+	return option, nil
+}
+
+// kindByName returns the kind with the given name, as returned by
+// Kind.String.
+func kindByName(name string) (Kind, bool) {
+	for kind, kindName := range kindNames {
+		if kindName == name {
+			return kind, true
+		}
+	}
+	return 0, false
+}
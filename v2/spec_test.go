@@ -0,0 +1,76 @@
+package v2
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	spec := `
+# options of the plugin
+verbose,v,none,Be verbose
+output, o, required, Write to FILE
+color,,optional,Colorize output, when possible
+,π,none,Print pi
+`
+	want := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "Be verbose"},
+		{Long: "output", Short: 'o', Kind: KindRequired, Help: "Write to FILE"},
+		{Long: "color", Kind: KindOptional, Help: "Colorize output, when possible"},
+		{Short: 'π', Kind: KindNone, Help: "Print pi"},
+	}
+
+	got, err := ParseSpec(spec)
+	if err != nil {
+		t.Fatalf("ParseSpec(), got error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSpec(), got %v, want %v", got, want)
+	}
+
+	results, _, err := ParseWithOutput(got, []string{"", "-vπ", "--output", "file"}, nil)
+	if err != nil {
+		t.Errorf("ParseWithOutput() with spec options, got error %v", err)
+	} else if err := MatchResults(results, "--verbose", "-π", "--output=file"); err != nil {
+		t.Errorf("ParseWithOutput() with spec options, %v", err)
+	}
+
+	if got, err := ParseSpec(""); got != nil || err != nil {
+		t.Errorf("ParseSpec(\"\"), got %v and %v, want nothing", got, err)
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	table := []struct {
+		spec string
+		want string
+	}{
+		{"verbose,v,none", "optparse: spec line 1: got 3 fields, want 4"},
+		{"verbose,v,none,Be verbose\nquiet", "optparse: spec line 2: got 1 fields, want 4"},
+		{"verbose,vv,none,Be verbose", `optparse: spec line 1: short name "vv" is not a single character`},
+		{"verbose,v,boolean,Be verbose", `optparse: spec line 1: unknown kind "boolean"`},
+		{"verbose,v,None,Be verbose", `optparse: spec line 1: unknown kind "None"`},
+		{",,none,Be nameless", "optparse: spec line 1: option has no name"},
+		{"\n\nverbose,v,none, ", "optparse: spec line 3: missing help field"},
+	}
+
+	for _, row := range table {
+		got, err := ParseSpec(row.spec)
+		if got != nil || err == nil || err.Error() != row.want {
+			t.Errorf("ParseSpec(%q), got %v and %v, want %q", row.spec, got, err, row.want)
+		}
+	}
+
+	// A line too long to read fails rather than cutting the spec short.
+	long := "verbose,v,none,Be verbose\nquiet,q,none," + strings.Repeat("x", 70000)
+	if got, err := ParseSpec(long); got != nil || !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("ParseSpec() with a long line, got %v and %v, want %q", got, err, bufio.ErrTooLong)
+	}
+
+	if _, err := ParseSpec(",,none,Be nameless"); !errors.Is(err, ErrUnnamed) {
+		t.Errorf("ParseSpec() without names, got %v, want %q", err, ErrUnnamed)
+	}
+}
@@ -0,0 +1,48 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+// TypedOption pairs an option with a function converting its argument
+// to a T, so that GetTyped can retrieve the argument as a T directly.
+// The embedded Option is what goes into the options given to the
+// parser, alongside plain ones:
+//
+//	jobs := TypedOption[int]{
+//		Option: Option{Long: "jobs", Short: 'j', Kind: KindRequired, Help: "run N jobs"},
+//		Parse:  strconv.Atoi,
+//	}
+//	results, rest, err := Parse([]Option{jobs.Option, verbose}, os.Args)
+//	...
+//	n, err := GetTyped(results, jobs)
+type TypedOption[T any] struct {
+	Option
+	Parse func(string) (T, error)
+}
+
+// GetTyped returns the argument of the last result for opt, converted
+// by its Parse function. If opt doesn't appear among the results, or
+// appears without an argument, its Default is converted instead, or
+// the zero value returned if it has none. An argument given explicitly
+// empty, as in "--jobs=", is converted like any other. A failed
+// conversion returns an ErrValue error.
+func GetTyped[T any](results []Result, opt TypedOption[T]) (T, error) {
+	optarg, given := opt.Default, false
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].is(opt.Long, opt.Short) {
+			if results[i].HasArg {
+				optarg, given = results[i].Optarg, true
+			}
+			break
+		}
+	}
+
+	var value T
+	if optarg == "" && !given {
+		return value, nil
+	}
+	value, err := opt.Parse(optarg)
+	if err != nil {
+		return value, Error{Option: opt.Option, Message: ErrValue, Err: err}
+	}
+	return value, nil
+}
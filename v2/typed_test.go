@@ -0,0 +1,94 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type level int
+
+const (
+	levelInfo level = iota
+	levelDebug
+)
+
+func parseLevel(s string) (level, error) {
+	switch s {
+	case "info":
+		return levelInfo, nil
+	case "debug":
+		return levelDebug, nil
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+func TestTypedOption(t *testing.T) {
+	jobs := TypedOption[int]{
+		Option: Option{Long: "jobs", Short: 'j', Kind: KindRequired, Help: "run N jobs", Default: "1"},
+		Parse:  strconv.Atoi,
+	}
+	timeout := TypedOption[time.Duration]{
+		Option: Option{Long: "timeout", Kind: KindRequired, Help: "give up after DURATION"},
+		Parse:  time.ParseDuration,
+	}
+	logLevel := TypedOption[level]{
+		Option: Option{Long: "level", Kind: KindRequired, Help: "log at LEVEL"},
+		Parse:  parseLevel,
+	}
+	typedOptions := []Option{
+		jobs.Option,
+		timeout.Option,
+		logLevel.Option,
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+	}
+
+	results, _, err := ParseWithOutput(typedOptions, []string{"", "-j", "2", "-v", "--timeout=1m30s", "-j8", "--level", "debug"}, nil)
+	if err != nil {
+		t.Fatalf("ParseWithOutput(), got error %v", err)
+	}
+	if n, err := GetTyped(results, jobs); err != nil || n != 8 {
+		t.Errorf("GetTyped(jobs), got %v, %v, want 8", n, err)
+	}
+	if d, err := GetTyped(results, timeout); err != nil || d != 90*time.Second {
+		t.Errorf("GetTyped(timeout), got %v, %v, want 1m30s", d, err)
+	}
+	if l, err := GetTyped(results, logLevel); err != nil || l != levelDebug {
+		t.Errorf("GetTyped(level), got %v, %v, want %v", l, err, levelDebug)
+	}
+
+	// Absent options fall back on their Default, or the zero value.
+	if n, err := GetTyped(nil, jobs); err != nil || n != 1 {
+		t.Errorf("GetTyped(jobs) when absent, got %v, %v, want 1", n, err)
+	}
+	if d, err := GetTyped(nil, timeout); err != nil || d != 0 {
+		t.Errorf("GetTyped(timeout) when absent, got %v, %v, want 0", d, err)
+	}
+
+	// Conversion errors are reported with the option.
+	results, _, _ = ParseWithOutput(typedOptions, []string{"", "--level=trace"}, nil)
+	_, err = GetTyped(results, logLevel)
+	want := `invalid argument for --level: unknown level "trace"`
+	if !errors.Is(err, ErrValue) || err.Error() != want {
+		t.Errorf("GetTyped(level), got %v, want %q", err, want)
+	}
+
+	// An explicitly empty argument is converted too, rather than
+	// taken as absent.
+	results, _, _ = ParseWithOutput(typedOptions, []string{"", "--jobs="}, nil)
+	if n, err := GetTyped(results, jobs); !errors.Is(err, ErrValue) {
+		t.Errorf("GetTyped(jobs) with --jobs=, got %v, %v, want %q", n, err, ErrValue)
+	}
+
+	// A bare optional argument falls back on the Default.
+	workers := TypedOption[int]{
+		Option: Option{Long: "workers", Kind: KindOptional, Help: "run [N] workers", Default: "3"},
+		Parse:  strconv.Atoi,
+	}
+	results, _, _ = ParseWithOutput([]Option{workers.Option}, []string{"", "--workers"}, nil)
+	if n, err := GetTyped(results, workers); err != nil || n != 3 {
+		t.Errorf("GetTyped(workers) with --workers, got %v, %v, want 3", n, err)
+	}
+}
@@ -0,0 +1,158 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Unmarshal parses args and stores the results in the struct pointed
+// to by ptr, returning the remaining arguments. Fields are bound to
+// options through struct tags:
+//
+//	type config struct {
+//		Verbose bool   `optparse:"verbose,v" help:"Be verbose"`
+//		Output  string `optparse:"output,o,required" help:"Write to FILE"`
+//		Retries int    `optparse:"retries" help:"Retry N times"`
+//	}
+//
+// The optparse tag holds the long name, the short name, and any flags,
+// separated by commas; either name may be left empty. The only flag is
+// "required", which sets Option.Required. Fields without the tag are
+// ignored.
+//
+// If options is nil, the options are derived from the tags: bool
+// fields become KindNone options, while string and int fields become
+// KindRequired ones, and the help tag provides the Help text.
+// Otherwise, options are used as given, and each tag must name one of
+// them.
+//
+// A bool field is set to true when its option is given, or false for
+// a negated one. A string field receives the argument, and an int
+// field the argument converted with Result.Int. As with
+// ParseWithOutput, a request for help returns ErrHelpRequested, with
+// the help summary written to standard output.
+func Unmarshal(ptr any, options []Option, args []string) ([]string, error) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("optparse: Unmarshal needs a pointer to a struct, got %T", ptr)
+	}
+	v = v.Elem()
+
+	fields, derived, err := taggedFields(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	if options == nil {
+		options = derived
+	} else {
+		for _, option := range derived {
+			if !hasOption(options, option) {
+				return nil, fmt.Errorf("optparse: no option matches tag %q", tagName(option))
+			}
+		}
+	}
+
+	results, rest, err := ParseWithOutput(options, args, nil)
+	if err != nil {
+		return rest, err
+	}
+
+	for _, result := range results {
+		for i, option := range derived {
+			if !result.is(option.Long, option.Short) {
+				continue
+			}
+			if err := store(v.Field(fields[i]), result); err != nil {
+				return rest, err
+			}
+		}
+	}
+	return rest, nil
+}
+
+// taggedFields returns the indices of the fields of struct type t that
+// have an optparse tag, along with the options derived from them.
+func taggedFields(t reflect.Type) ([]int, []Option, error) {
+	var fields []int
+	var options []Option
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("optparse")
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		option := Option{Long: parts[0], Help: field.Tag.Get("help")}
+		if len(parts) > 1 && parts[1] != "" {
+			short, size := utf8.DecodeRuneInString(parts[1])
+			if size != len(parts[1]) {
+				return nil, nil, fmt.Errorf("optparse: field %s: short name %q is not a single character", field.Name, parts[1])
+			}
+			option.Short = short
+		}
+		for _, flag := range parts[min(len(parts), 2):] {
+			if flag != "required" {
+				return nil, nil, fmt.Errorf("optparse: field %s: unknown flag %q", field.Name, flag)
+			}
+			option.Required = true
+		}
+		if option.Long == "" && option.Short == 0 {
+			return nil, nil, fmt.Errorf("optparse: field %s: tag names no option", field.Name)
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			option.Kind = KindNone
+		case reflect.String, reflect.Int:
+			option.Kind = KindRequired
+		default:
+			return nil, nil, fmt.Errorf("optparse: field %s: unsupported type %s", field.Name, field.Type)
+		}
+
+		fields = append(fields, i)
+		options = append(options, option)
+	}
+	return fields, options, nil
+}
+
+// hasOption reports whether options contains one with the same names
+// as option.
+func hasOption(options []Option, option Option) bool {
+	for _, o := range options {
+		if o.Long == option.Long && o.Short == option.Short {
+			return true
+		}
+	}
+	return false
+}
+
+// tagName renders the names of an option as written in its tag.
+func tagName(option Option) string {
+	if option.Short == 0 {
+		return option.Long
+	}
+	return fmt.Sprintf("%s,%c", option.Long, option.Short)
+}
+
+// store sets field from result, according to the field's type.
+func store(field reflect.Value, result Result) error {
+	switch field.Kind() {
+	case reflect.Bool:
+		field.SetBool(!result.Negated)
+	case reflect.String:
+		field.SetString(result.Optarg)
+	case reflect.Int:
+		n, err := result.Int()
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package v2
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type unmarshalConfig struct {
+	Verbose bool   `optparse:"verbose,v" help:"be verbose"`
+	Output  string `optparse:"output,o,required" help:"write to FILE"`
+	Retries int    `optparse:"retries" help:"retry N times"`
+	Quiet   bool   `optparse:",q" help:"say nothing"`
+	Ignored string
+}
+
+func TestUnmarshal(t *testing.T) {
+	var conf unmarshalConfig
+	rest, err := Unmarshal(&conf, nil, []string{"", "-v", "--output", "out.txt", "--retries=3", "file"})
+	if err != nil {
+		t.Fatalf("Unmarshal(), got error %v", err)
+	}
+	want := unmarshalConfig{Verbose: true, Output: "out.txt", Retries: 3}
+	if conf != want {
+		t.Errorf("Unmarshal(), got %+v, want %+v", conf, want)
+	}
+	if !equal(rest, []string{"file"}) {
+		t.Errorf("Unmarshal(), got rest %v, want [file]", rest)
+	}
+
+	// Options can be supplied instead of derived from tags.
+	conf = unmarshalConfig{}
+	given := []Option{
+		{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose", Negatable: true},
+		{Long: "output", Short: 'o', Kind: KindRequired, Help: "write to FILE"},
+		{Long: "retries", Kind: KindRequired, Help: "retry N times"},
+		{Short: 'q', Kind: KindNone, Help: "say nothing"},
+	}
+	if _, err := Unmarshal(&conf, given, []string{"", "-v", "--no-verbose", "-q", "-oout"}); err != nil {
+		t.Fatalf("Unmarshal() with options, got error %v", err)
+	}
+	want = unmarshalConfig{Verbose: false, Output: "out", Quiet: true}
+	if conf != want {
+		t.Errorf("Unmarshal() with options, got %+v, want %+v", conf, want)
+	}
+
+	// Conversion errors name the option.
+	_, err = Unmarshal(&conf, nil, []string{"", "-o", "x", "--retries", "many"})
+	if err == nil || !strings.Contains(err.Error(), "--retries") {
+		t.Errorf("Unmarshal() with a bad int, got %v, want an error naming --retries", err)
+	}
+
+	// Required options are enforced.
+	_, err = Unmarshal(&conf, nil, []string{""})
+	if !errors.Is(err, ErrRequired) {
+		t.Errorf("Unmarshal() without --output, got %v, want %q", err, ErrRequired)
+	}
+
+	// Tags must match the given options.
+	_, err = Unmarshal(&conf, given[:2], []string{""})
+	if err == nil || !strings.Contains(err.Error(), `"retries"`) {
+		t.Errorf("Unmarshal() with a missing option, got %v", err)
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	var badShort struct {
+		X bool `optparse:"x,xy" help:"x"`
+	}
+	var badType struct {
+		X float64 `optparse:"x" help:"x"`
+	}
+	var badFlag struct {
+		X bool `optparse:"x,,sometimes" help:"x"`
+	}
+
+	for _, ptr := range []any{unmarshalConfig{}, new(int), &badShort, &badType, &badFlag} {
+		if _, err := Unmarshal(ptr, nil, []string{""}); err == nil {
+			t.Errorf("Unmarshal(%s), expected an error", reflect.TypeOf(ptr))
+		}
+	}
+}
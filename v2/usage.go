@@ -0,0 +1,82 @@
+// This is free and unencumbered software released into the public domain.
+
+package v2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Usage builds a one-line synopsis of the command line accepted by
+// progName, such as:
+//
+//	usage: prog [-v] [-o OUTPUT] [--color[=COLOR]] [ARGS...]
+//
+// Each option is written in its short form when it has one, and its
+// long form otherwise. Options taking an argument are followed by a
+// placeholder for it, which is itself bracketed when the argument is
+// optional. Options are bracketed unless they are Required. Hidden
+// options are left out.
+func Usage(progName string, options []Option) string {
+	return UsageArgs(progName, options, nil)
+}
+
+// UsageArgs is like Usage, but ends the synopsis with the positional
+// arguments declared by specs, such as "SRC... DEST", instead of a
+// generic "[ARGS...]". A nil specs gives the same synopsis as Usage,
+// while an empty one leaves positionals out.
+func UsageArgs(progName string, options []Option, specs []ArgSpec) string {
+	words := []string{"usage:", progName}
+	for _, option := range options {
+		if option.Hidden {
+			continue
+		}
+		word := synopsis(option)
+		if !option.Required {
+			word = "[" + word + "]"
+		}
+		words = append(words, word)
+	}
+	if specs == nil {
+		words = append(words, "[ARGS...]")
+	}
+	for _, spec := range specs {
+		if word := argSynopsis(spec); word != "" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// synopsis renders a single option as it appears in Usage.
+func synopsis(option Option) string {
+	var flag string
+	if option.Short != 0 {
+		flag = fmt.Sprintf("-%c", option.Short)
+	} else {
+		flag = "--" + option.Long
+	}
+
+	switch option.Kind {
+	case KindRequired:
+		return flag + " " + metavar(option)
+	case KindOptional:
+		if option.Short != 0 {
+			return flag + "[" + metavar(option) + "]"
+		}
+		return flag + "[=" + metavar(option) + "]"
+	}
+	return flag
+}
+
+// metavar returns the placeholder naming an option's argument: its
+// Metavar if set, otherwise its long name in upper case, or ARG for
+// short-only options.
+func metavar(option Option) string {
+	if option.Metavar != "" {
+		return option.Metavar
+	} else if option.Long == "" {
+		return "ARG"
+	}
+	return strings.ToUpper(option.Long)
+}
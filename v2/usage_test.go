@@ -0,0 +1,44 @@
+package v2
+
+import "testing"
+
+func TestUsage(t *testing.T) {
+	table := []struct {
+		options []Option
+		want    string
+	}{
+		{
+			nil,
+			"usage: prog [ARGS...]",
+		},
+		{
+			[]Option{
+				{Long: "verbose", Short: 'v', Kind: KindNone, Help: "be verbose"},
+				{Long: "output", Kind: KindRequired, Help: "write to FILE"},
+			},
+			"usage: prog [-v] [--output OUTPUT] [ARGS...]",
+		},
+		{
+			[]Option{
+				{Long: "color", Short: 'c', Kind: KindOptional, Help: "colorize output"},
+				{Long: "width", Kind: KindOptional, Help: "wrap lines"},
+				{Short: 'n', Kind: KindRequired, Help: "repeat N times"},
+			},
+			"usage: prog [-c[COLOR]] [--width[=WIDTH]] [-n ARG] [ARGS...]",
+		},
+		{
+			[]Option{
+				{Long: "input", Short: 'i', Kind: KindRequired, Help: "read FILE", Required: true},
+				{Long: "dry-run", Kind: KindNone, Help: "change nothing", Required: true},
+				{Long: "quiet", Short: 'q', Kind: KindNone, Help: "say nothing"},
+			},
+			"usage: prog -i INPUT --dry-run [-q] [ARGS...]",
+		},
+	}
+
+	for _, row := range table {
+		if got := Usage("prog", row.options); got != row.want {
+			t.Errorf("Usage(%v), got %q, want %q", row.options, got, row.want)
+		}
+	}
+}